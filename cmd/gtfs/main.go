@@ -0,0 +1,82 @@
+// Command gtfs writes a GTFS static feed of TfL timetables to a zip file.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/arunsworld/tfl/gtfs"
+)
+
+func main() {
+	out := flag.String("out", "gtfs.zip", "file to write the feed to")
+	modes := flag.String("modes", "tube", "comma separated modes whose lines are included")
+	days := flag.Int("days", 7, "number of days from today the calendar runs for")
+	baseURL := flag.String("tfl-base-url", "", "TfL API base URL (env TFL_BASE_URL; default "+tfl.DefaultBaseURL+")")
+	appID := flag.String("tfl-app-id", "", "TfL API app_id (env TFL_APP_ID)")
+	appKey := flag.String("tfl-app-key", "", "TfL API app_key (env TFL_APP_KEY)")
+	replayDir := flag.String("replay", "", "read TfL responses from this directory instead of the network")
+	bankHolidays := flag.String("bank-holidays", "", "England & Wales bank holidays in the format of https://www.gov.uk/bank-holidays.json (default: built in)")
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	opts := []tfl.Option{
+		tfl.WithCredentials(flagOrEnv(*appID, "TFL_APP_ID"), flagOrEnv(*appKey, "TFL_APP_KEY")),
+		// a feed needs many timetables, each of which is slow to build
+		tfl.WithRequestTimeout(time.Minute),
+	}
+	if v := flagOrEnv(*baseURL, "TFL_BASE_URL"); v != "" {
+		opts = append(opts, tfl.WithBaseURL(v))
+	}
+	if *replayDir != "" {
+		opts = append(opts, tfl.WithReplay(*replayDir))
+	}
+	if *bankHolidays != "" {
+		opts = append(opts, tfl.WithBankHolidays(*bankHolidays))
+	}
+	api := tfl.New(opts...)
+	defer api.Close()
+
+	if err := run(ctx, api, *out, strings.Split(*modes, ","), *days); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, api tfl.TFLAPI, out string, modes []string, days int) error {
+	feed, err := gtfs.Generate(ctx, api, gtfs.WithModes(modes...), gtfs.WithValidity(time.Now(), days))
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	if err := feed.WriteZip(f); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", out, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	slog.Info("wrote GTFS feed", "file", out, "routes", len(feed.Routes), "stops", len(feed.Stops), "trips", len(feed.Trips))
+	return nil
+}
+
+// flagOrEnv prefers an explicitly set flag value and falls back to the environment.
+func flagOrEnv(flagValue, envKey string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return os.Getenv(envKey)
+}
@@ -4,13 +4,18 @@ import (
 	"context"
 	"embed"
 	"flag"
+	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/arunsworld/tfl"
 	"github.com/arunsworld/tfl/handlers"
+	"github.com/arunsworld/tfl/metrics"
 	"github.com/arunsworld/tfl/webserver"
 	"github.com/gorilla/mux"
 )
@@ -20,19 +25,72 @@ var webContent embed.FS
 
 func main() {
 	port := flag.Int("port", 4934, "port to run watermill client on")
+	baseURL := flag.String("tfl-base-url", "", "TfL API base URL (env TFL_BASE_URL; default "+tfl.DefaultBaseURL+")")
+	appID := flag.String("tfl-app-id", "", "TfL API app_id (env TFL_APP_ID)")
+	appKey := flag.String("tfl-app-key", "", "TfL API app_key (env TFL_APP_KEY)")
+	recordDir := flag.String("record", "", "record every TfL response into this directory")
+	replayDir := flag.String("replay", "", "serve TfL responses from this directory (see -record) instead of the network")
+	snapshotPath := flag.String("snapshot", "", "persist static network data to this file and warm the caches from it at startup")
+	snapshotInterval := flag.Duration("snapshot-interval", 10*time.Minute, "how often to write the snapshot")
+	warmup := flag.String("warmup", "tube", "comma separated modes whose lines, stations and routes are fetched at startup; /readyz fails until they are")
+	logFormat := flag.String("log-format", "text", "log output format: text or json")
+	logLevel := flag.String("log-level", "info", "minimum log level: debug, info, warn or error")
+	bankHolidays := flag.String("bank-holidays", "", "England & Wales bank holidays in the format of https://www.gov.uk/bank-holidays.json (default: built in)")
 	flag.Parse()
 
-	if err := start(*port); err != nil {
-		log.Fatal(err)
+	logger, err := newLogger(*logFormat, *logLevel)
+	if err != nil {
+		fatal(err)
+	}
+	slog.SetDefault(logger)
+
+	if *recordDir != "" && *replayDir != "" {
+		fatal(fmt.Errorf("-record and -replay are mutually exclusive"))
+	}
+
+	opts := []tfl.Option{
+		tfl.WithLogger(logger),
+		tfl.WithCredentials(flagOrEnv(*appID, "TFL_APP_ID"), flagOrEnv(*appKey, "TFL_APP_KEY")),
+	}
+	if v := flagOrEnv(*baseURL, "TFL_BASE_URL"); v != "" {
+		opts = append(opts, tfl.WithBaseURL(v))
+	}
+	if *recordDir != "" {
+		opts = append(opts, tfl.WithRecording(*recordDir))
+	}
+	if *replayDir != "" {
+		opts = append(opts, tfl.WithReplay(*replayDir))
+	}
+	if *warmup != "" {
+		opts = append(opts, tfl.WithWarmup(strings.Split(*warmup, ",")...))
+	}
+	if *snapshotPath != "" {
+		opts = append(opts, tfl.WithSnapshot(*snapshotPath, *snapshotInterval))
+	}
+	if *bankHolidays != "" {
+		opts = append(opts, tfl.WithBankHolidays(*bankHolidays))
+	}
+
+	if err := start(*port, logger, opts); err != nil {
+		fatal(err)
 	}
 }
 
-func start(port int) error {
+func start(port int, logger *slog.Logger, opts []tfl.Option) error {
 	shutdownCtx, shutdown := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer shutdown()
 
+	reg := metrics.NewRegistry()
+	api := tfl.New(append(opts, tfl.WithMetrics(reg))...)
+	defer func() {
+		if err := api.Close(); err != nil {
+			logger.Error("closing TfL API", "error", err)
+		}
+	}()
+
 	handler := mux.NewRouter()
-	handlers.RegisterHandlers(handler, mustFSSub(webContent, "embed/static"), mustFSSub(webContent, "embed/html"))
+	handlers.RegisterHandlers(handler, api, mustFSSub(webContent, "embed/static"), mustFSSub(webContent, "embed/html"), handlers.WithMetrics(reg), handlers.WithLogger(logger),
+		handlers.WithContext(shutdownCtx))
 
 	if err := webserver.NewHTTPWebServer(handler).Serve(shutdownCtx, port); err != nil {
 		return err
@@ -41,6 +99,36 @@ func start(port int) error {
 	return nil
 }
 
+func newLogger(format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid -log-level %q: %w", level, err)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid -log-format %q: expected text or json", format)
+	}
+}
+
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+// flagOrEnv prefers an explicitly set flag value and falls back to the environment.
+// Secrets are kept out of flag defaults so they don't show up in -help output.
+func flagOrEnv(flagValue, envKey string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return os.Getenv(envKey)
+}
+
 func mustFSSub(src fs.FS, dir string) fs.FS {
 	fsys, err := fs.Sub(src, dir)
 	if err != nil {
@@ -1,6 +1,6 @@
 module github.com/arunsworld/tfl
 
-go 1.18
+go 1.21
 
 require (
 	github.com/gorilla/mux v1.8.0
@@ -0,0 +1,199 @@
+// Package gtfs builds GTFS static feeds (https://gtfs.org/schedule/reference/) from TfL timetables.
+package gtfs
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Feed is a GTFS feed; only the files TfL has data for are written.
+type Feed struct {
+	Agencies  []Agency
+	Stops     []Stop
+	Routes    []Route
+	Trips     []Trip
+	StopTimes []StopTime
+	Calendars []Calendar
+	// CalendarDates are exceptions to Calendars, eg. on bank holidays
+	CalendarDates []CalendarDate
+}
+
+type Agency struct {
+	ID       string
+	Name     string
+	URL      string
+	Timezone string
+}
+
+type Stop struct {
+	ID       string
+	Name     string
+	Lat, Lon float64
+}
+
+// RouteType is the GTFS route_type.
+type RouteType int
+
+const (
+	RouteTypeTram    RouteType = 0
+	RouteTypeSubway  RouteType = 1
+	RouteTypeRail    RouteType = 2
+	RouteTypeBus     RouteType = 3
+	RouteTypeFerry   RouteType = 4
+	RouteTypeGondola RouteType = 6
+)
+
+type Route struct {
+	ID        string
+	AgencyID  string
+	ShortName string
+	LongName  string
+	Type      RouteType
+}
+
+type Trip struct {
+	RouteID   string
+	ServiceID string
+	ID        string
+	Headsign  string
+}
+
+// StopTime times are offsets from the start of the service day and may exceed 24 hours.
+type StopTime struct {
+	TripID       string
+	Arrival      time.Duration
+	Departure    time.Duration
+	StopID       string
+	StopSequence int
+}
+
+// Calendar runs a service on Weekdays between Start and End inclusive.
+type Calendar struct {
+	ServiceID string
+	Weekdays  []time.Weekday
+	Start     time.Time
+	End       time.Time
+}
+
+func (c Calendar) runsOn(weekday time.Weekday) string {
+	for _, d := range c.Weekdays {
+		if d == weekday {
+			return "1"
+		}
+	}
+	return "0"
+}
+
+// ExceptionType is the GTFS exception_type of a CalendarDate.
+type ExceptionType int
+
+const (
+	ServiceAdded   ExceptionType = 1
+	ServiceRemoved ExceptionType = 2
+)
+
+type CalendarDate struct {
+	ServiceID string
+	Date      time.Time
+	Exception ExceptionType
+}
+
+// WriteZip writes the feed as a GTFS zip archive.
+func (f *Feed) WriteZip(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name   string
+		header []string
+		rows   [][]string
+	}{
+		{"agency.txt", []string{"agency_id", "agency_name", "agency_url", "agency_timezone"}, f.agencyRows()},
+		{"stops.txt", []string{"stop_id", "stop_name", "stop_lat", "stop_lon"}, f.stopRows()},
+		{"routes.txt", []string{"route_id", "agency_id", "route_short_name", "route_long_name", "route_type"}, f.routeRows()},
+		{"trips.txt", []string{"route_id", "service_id", "trip_id", "trip_headsign"}, f.tripRows()},
+		{"stop_times.txt", []string{"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"}, f.stopTimeRows()},
+		{"calendar.txt", []string{"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"}, f.calendarRows()},
+		{"calendar_dates.txt", []string{"service_id", "date", "exception_type"}, f.calendarDateRows()},
+	}
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return fmt.Errorf("creating %s: %w", file.name, err)
+		}
+		cw := csv.NewWriter(fw)
+		cw.Write(file.header)
+		cw.WriteAll(file.rows)
+		if err := cw.Error(); err != nil {
+			return fmt.Errorf("writing %s: %w", file.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+func (f *Feed) agencyRows() [][]string {
+	rows := make([][]string, 0, len(f.Agencies))
+	for _, a := range f.Agencies {
+		rows = append(rows, []string{a.ID, a.Name, a.URL, a.Timezone})
+	}
+	return rows
+}
+
+func (f *Feed) stopRows() [][]string {
+	rows := make([][]string, 0, len(f.Stops))
+	for _, s := range f.Stops {
+		rows = append(rows, []string{s.ID, s.Name, strconv.FormatFloat(s.Lat, 'f', -1, 64), strconv.FormatFloat(s.Lon, 'f', -1, 64)})
+	}
+	return rows
+}
+
+func (f *Feed) routeRows() [][]string {
+	rows := make([][]string, 0, len(f.Routes))
+	for _, r := range f.Routes {
+		rows = append(rows, []string{r.ID, r.AgencyID, r.ShortName, r.LongName, strconv.Itoa(int(r.Type))})
+	}
+	return rows
+}
+
+func (f *Feed) tripRows() [][]string {
+	rows := make([][]string, 0, len(f.Trips))
+	for _, t := range f.Trips {
+		rows = append(rows, []string{t.RouteID, t.ServiceID, t.ID, t.Headsign})
+	}
+	return rows
+}
+
+func (f *Feed) stopTimeRows() [][]string {
+	rows := make([][]string, 0, len(f.StopTimes))
+	for _, st := range f.StopTimes {
+		rows = append(rows, []string{st.TripID, formatTime(st.Arrival), formatTime(st.Departure), st.StopID, strconv.Itoa(st.StopSequence)})
+	}
+	return rows
+}
+
+func (f *Feed) calendarRows() [][]string {
+	rows := make([][]string, 0, len(f.Calendars))
+	for _, c := range f.Calendars {
+		rows = append(rows, []string{c.ServiceID,
+			c.runsOn(time.Monday), c.runsOn(time.Tuesday), c.runsOn(time.Wednesday), c.runsOn(time.Thursday),
+			c.runsOn(time.Friday), c.runsOn(time.Saturday), c.runsOn(time.Sunday),
+			c.Start.Format("20060102"), c.End.Format("20060102")})
+	}
+	return rows
+}
+
+func (f *Feed) calendarDateRows() [][]string {
+	rows := make([][]string, 0, len(f.CalendarDates))
+	for _, cd := range f.CalendarDates {
+		rows = append(rows, []string{cd.ServiceID, cd.Date.Format("20060102"), strconv.Itoa(int(cd.Exception))})
+	}
+	return rows
+}
+
+// formatTime writes an offset from the start of the service day as HH:MM:SS
+func formatTime(d time.Duration) string {
+	s := int(d.Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
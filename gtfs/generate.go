@@ -0,0 +1,318 @@
+package gtfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arunsworld/tfl"
+)
+
+// Option configures Generate.
+type Option func(*config)
+
+type config struct {
+	modes  []string
+	start  time.Time
+	days   int
+	logger *slog.Logger
+}
+
+func defaultConfig() config {
+	return config{
+		modes:  []string{"tube"},
+		start:  time.Now(),
+		days:   7,
+		logger: slog.Default(),
+	}
+}
+
+// WithModes sets the TfL modes, eg. "tube" or "dlr", whose lines are included. The default is tube.
+func WithModes(modes ...string) Option {
+	return func(cfg *config) {
+		cfg.modes = modes
+	}
+}
+
+// WithValidity makes the calendar run for days days from the London date of start.
+// The default is a week from today; TfL only publishes the current timetable.
+func WithValidity(start time.Time, days int) Option {
+	return func(cfg *config) {
+		cfg.start = start
+		if days > 0 {
+			cfg.days = days
+		}
+	}
+}
+
+// WithLogger sets the logger used to report routes skipped for lack of a timetable.
+func WithLogger(l *slog.Logger) Option {
+	return func(cfg *config) {
+		cfg.logger = l
+	}
+}
+
+var tflAgency = Agency{ID: "TfL", Name: "Transport for London", URL: "https://tfl.gov.uk", Timezone: "Europe/London"}
+
+var london = mustLoadLocation("Europe/London")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+func routeTypeFor(mode string) RouteType {
+	switch mode {
+	case "tube":
+		return RouteTypeSubway
+	case "dlr", "tram":
+		return RouteTypeTram
+	case "bus":
+		return RouteTypeBus
+	case "river-bus", "river-tour":
+		return RouteTypeFerry
+	case "cable-car":
+		return RouteTypeGondola
+	default:
+		return RouteTypeRail
+	}
+}
+
+// Generate builds a feed from the timetables of every route of every line of the configured modes.
+// Each line is a GTFS route and each TfL route is fetched as the timetable from its first to its last station;
+// routes TfL has no timetable for are skipped.
+func Generate(ctx context.Context, api tfl.TFLAPI, opts ...Option) (*Feed, error) {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	g := generator{
+		cfg:      cfg,
+		api:      api,
+		feed:     &Feed{Agencies: []Agency{tflAgency}},
+		stops:    make(map[string]bool),
+		services: make(map[string]string),
+	}
+	for _, mode := range cfg.modes {
+		lines, err := api.LookupLines(ctx, mode, false)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			cfg.logger.Warn("skipping mode without lines", "mode", mode, "error", err)
+			g.failed(err)
+		} else if len(lines) == 0 {
+			cfg.logger.Warn("no lines found for mode", "mode", mode)
+		}
+		for _, line := range lines {
+			if err := g.addLine(ctx, mode, line); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(g.feed.Trips) == 0 {
+		cause := g.err
+		if cause == nil {
+			cause = tfl.ErrNotFound
+		}
+		return nil, fmt.Errorf("no timetables found for %s: %w", strings.Join(cfg.modes, ", "), cause)
+	}
+	return g.feed, nil
+}
+
+type generator struct {
+	cfg      config
+	api      tfl.TFLAPI
+	feed     *Feed
+	stops    map[string]bool
+	services map[string]string
+	// err explains an empty feed; TfL being unavailable is preferred over TfL not knowing a timetable
+	err error
+}
+
+func (g *generator) failed(err error) {
+	if g.err == nil || errors.Is(err, tfl.ErrUpstreamUnavailable) && !errors.Is(g.err, tfl.ErrUpstreamUnavailable) {
+		g.err = err
+	}
+}
+
+func (g *generator) addLine(ctx context.Context, mode string, line tfl.Line) error {
+	g.feed.Routes = append(g.feed.Routes, Route{
+		ID:        line.ID,
+		AgencyID:  tflAgency.ID,
+		ShortName: line.Name,
+		LongName:  line.Name,
+		Type:      routeTypeFor(mode),
+	})
+	for _, s := range g.api.StationsContext(ctx, line.ID) {
+		g.addStop(s)
+	}
+	// branches sharing their first and last station share a timetable
+	fetched := make(map[[2]string]bool)
+	routes, err := g.api.LookupRoutes(ctx, line.ID)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		g.cfg.logger.Warn("skipping line without routes", "line", line.ID, "error", err)
+		g.failed(err)
+		return nil
+	}
+	for _, route := range routes {
+		key := [2]string{route.Start(), route.Dest()}
+		if key[0] == "" || key[1] == "" || fetched[key] {
+			continue
+		}
+		fetched[key] = true
+		tt, err := g.api.TimetableContext(ctx, line.ID, key[0], key[1])
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			g.cfg.logger.Warn("skipping route without timetable", "line", line.ID, "route", route.Name, "error", err)
+			g.failed(err)
+			continue
+		}
+		g.addTimetable(line.ID, tt)
+	}
+	return nil
+}
+
+func (g *generator) addStop(s tfl.Station) {
+	if g.stops[s.ID] {
+		return
+	}
+	g.stops[s.ID] = true
+	g.feed.Stops = append(g.feed.Stops, Stop{ID: s.ID, Name: s.ShortName(), Lat: s.Lat, Lon: s.Lon})
+}
+
+func (g *generator) addTimetable(lineID string, tt tfl.Timetable) {
+	g.addStop(tt.From)
+	// bank holidays running another schedule are removed from the weekday's usual one
+	added := make([][]time.Time, len(tt.Schedules))
+	holidays := make(map[string]time.Time)
+	for i, schedule := range tt.Schedules {
+		for _, h := range schedule.BankHolidays {
+			if g.inValidity(h) {
+				added[i] = append(added[i], h)
+				holidays[h.Format("20060102")] = h
+			}
+		}
+	}
+	for i, schedule := range tt.Schedules {
+		var removed []time.Time
+		for _, h := range holidays {
+			if runsOn(schedule.Weekdays, h.Weekday()) && !contains(added[i], h) {
+				removed = append(removed, h)
+			}
+		}
+		sort.Slice(removed, func(i, j int) bool { return removed[i].Before(removed[j]) })
+		serviceID := g.service(schedule.Weekdays, added[i], removed)
+		for _, j := range schedule.Journeys {
+			departure := j.DepartureTime.Time.SinceStart()
+			// branches may share a departure time
+			tripID := fmt.Sprintf("%s-%s-%s-%s-%02d%02d-%d-%s", lineID, tt.From.ID, tt.To.ID, serviceID,
+				int(departure.Hours()), int(departure.Minutes())%60, j.DepartureTime.Branch, j.DepartureTime.Destination.ID)
+			trip := Trip{
+				RouteID:   lineID,
+				ServiceID: serviceID,
+				ID:        tripID,
+				Headsign:  tt.To.ShortName(),
+			}
+			if j.DepartureTime.Destination.ID != "" {
+				trip.Headsign = j.DepartureTime.Destination.ShortName()
+			}
+			g.feed.Trips = append(g.feed.Trips, trip)
+			stops := j.Stops
+			// TfL lists the stops after the origin
+			if len(stops) == 0 || stops[0].Station.ID != tt.From.ID {
+				stops = append([]tfl.ScheduledStop{{Station: tt.From}}, stops...)
+			}
+			for i, s := range stops {
+				g.addStop(s.Station)
+				at := departure + s.TimeToArrival
+				g.feed.StopTimes = append(g.feed.StopTimes, StopTime{TripID: trip.ID, Arrival: at, Departure: at, StopID: s.Station.ID, StopSequence: i + 1})
+			}
+		}
+	}
+}
+
+// service returns the ID of the calendar running on weekdays, plus the added and minus the removed dates,
+// adding it on first use. Lines disagreeing on a bank holiday get their own variant of the weekday calendar.
+func (g *generator) service(weekdays []time.Weekday, added, removed []time.Time) string {
+	names := make([]string, 0, len(weekdays))
+	for _, d := range weekdays {
+		names = append(names, strings.ToLower(d.String()[:3]))
+	}
+	base := strings.Join(names, "_")
+	if base == "" {
+		base = "bank_holidays"
+	}
+	key := base
+	for _, d := range added {
+		key += "+" + d.Format("20060102")
+	}
+	for _, d := range removed {
+		key += "-" + d.Format("20060102")
+	}
+	if id, ok := g.services[key]; ok {
+		return id
+	}
+	id := base
+	if key != base {
+		id = fmt.Sprintf("%s_%d", base, len(g.feed.Calendars)+1)
+	}
+	g.services[key] = id
+	start, end := g.validity()
+	g.feed.Calendars = append(g.feed.Calendars, Calendar{
+		ServiceID: id,
+		Weekdays:  weekdays,
+		Start:     start,
+		End:       end,
+	})
+	for _, d := range added {
+		g.feed.CalendarDates = append(g.feed.CalendarDates, CalendarDate{ServiceID: id, Date: d, Exception: ServiceAdded})
+	}
+	for _, d := range removed {
+		g.feed.CalendarDates = append(g.feed.CalendarDates, CalendarDate{ServiceID: id, Date: d, Exception: ServiceRemoved})
+	}
+	return id
+}
+
+// validity is the first and last London date the calendars run on
+func (g *generator) validity() (time.Time, time.Time) {
+	y, m, d := g.cfg.start.In(london).Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, london)
+	return start, start.AddDate(0, 0, g.cfg.days-1)
+}
+
+func (g *generator) inValidity(date time.Time) bool {
+	start, end := g.validity()
+	day := date.In(london).Format("20060102")
+	return day >= start.Format("20060102") && day <= end.Format("20060102")
+}
+
+func runsOn(weekdays []time.Weekday, weekday time.Weekday) bool {
+	for _, d := range weekdays {
+		if d == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(dates []time.Time, date time.Time) bool {
+	for _, d := range dates {
+		if d.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
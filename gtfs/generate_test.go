@@ -0,0 +1,144 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/arunsworld/tfl/tfltest"
+)
+
+func TestGenerate(t *testing.T) {
+	// a bank holiday on the coming Monday, so it's inside the feed and after TfL's timetable was fetched
+	today := time.Now().In(london)
+	start := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, london)
+	daysToMonday := (8 - int(today.Weekday())) % 7
+	if daysToMonday == 0 {
+		daysToMonday = 7
+	}
+	holiday := start.AddDate(0, 0, daysToMonday)
+	bankHolidays := filepath.Join(t.TempDir(), "bank-holidays.json")
+	err := os.WriteFile(bankHolidays, []byte(fmt.Sprintf(`{"england-and-wales": {"division": "england-and-wales", "events": [
+		{"title": "Summer bank holiday", "date": "%s"}
+	]}}`, holiday.Format("2006-01-02"))), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := tfltest.NewServer()
+	defer srv.Close()
+	srv.SetLines("tube", tfltest.Line{ID: "victoria", Name: "Victoria"})
+	srv.SetStopPoints("victoria",
+		tfltest.StopPoint{ID: "940GZZLUBXN", CommonName: "Brixton Underground Station", Lat: 51.4627, Lon: -0.1145},
+		tfltest.StopPoint{ID: "940GZZLUVIC", CommonName: "Victoria Underground Station", Lat: 51.4965, Lon: -0.1447},
+		tfltest.StopPoint{ID: "940GZZLUWWL", CommonName: "Walthamstow Central Underground Station", Lat: 51.583, Lon: -0.0197},
+	)
+	srv.SetRouteSequence("victoria", tfltest.OrderedLineRoute{
+		Name:      "Brixton - Walthamstow Central",
+		NaptanIDs: []string{"940GZZLUBXN", "940GZZLUVIC", "940GZZLUWWL"},
+	})
+	srv.SetTimetable("victoria", "940GZZLUBXN", "940GZZLUWWL", tfltest.Timetable{
+		Stops: []tfltest.TimetableStop{
+			{ID: "940GZZLUBXN", Name: "Brixton Underground Station"},
+			{ID: "940GZZLUVIC", Name: "Victoria Underground Station"},
+			{ID: "940GZZLUWWL", Name: "Walthamstow Central Underground Station"},
+		},
+		Timetable: tfltest.TimetableRouteList{Routes: []tfltest.TimetableRoute{{
+			StationIntervals: []tfltest.StationInterval{{
+				ID: "0",
+				Intervals: []tfltest.Interval{
+					{StopID: "940GZZLUVIC", TimeToArrival: 6},
+					{StopID: "940GZZLUWWL", TimeToArrival: 32},
+				},
+			}},
+			Schedules: []tfltest.Schedule{
+				{Name: "Monday - Friday", KnownJourneys: []tfltest.KnownJourney{{Hour: "5", Minute: "35"}}},
+				{Name: "Saturday", KnownJourneys: []tfltest.KnownJourney{{Hour: "25", Minute: "30"}}},
+				{Name: "Sunday and Bank Holidays", KnownJourneys: []tfltest.KnownJourney{{Hour: "7", Minute: "0"}}},
+			},
+		}}},
+	})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	api := tfl.New(tfl.WithBaseURL(srv.URL), tfl.WithRetry(1, 0, 0), tfl.WithLogger(logger), tfl.WithBankHolidays(bankHolidays))
+	defer api.Close()
+
+	feed, err := Generate(context.Background(), api, WithValidity(today, 14), WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var zipped bytes.Buffer
+	if err := feed.WriteZip(&zipped); err != nil {
+		t.Fatal(err)
+	}
+	files := readZip(t, zipped.Bytes())
+
+	first, last := start.Format("20060102"), start.AddDate(0, 0, 13).Format("20060102")
+	expectFile(t, files, "calendar.txt", [][]string{
+		{"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"},
+		{"mon_tue_wed_thu_fri_1", "1", "1", "1", "1", "1", "0", "0", first, last},
+		{"sat", "0", "0", "0", "0", "0", "1", "0", first, last},
+		{"sun_3", "0", "0", "0", "0", "0", "0", "1", first, last},
+	})
+	// the holiday moves from the weekday calendar onto Sunday's
+	expectFile(t, files, "calendar_dates.txt", [][]string{
+		{"service_id", "date", "exception_type"},
+		{"mon_tue_wed_thu_fri_1", holiday.Format("20060102"), "2"},
+		{"sun_3", holiday.Format("20060102"), "1"},
+	})
+	expectFile(t, files, "stop_times.txt", [][]string{
+		{"trip_id", "arrival_time", "departure_time", "stop_id", "stop_sequence"},
+		{"victoria-940GZZLUBXN-940GZZLUWWL-mon_tue_wed_thu_fri_1-0535-1-940GZZLUWWL", "05:35:00", "05:35:00", "940GZZLUBXN", "1"},
+		{"victoria-940GZZLUBXN-940GZZLUWWL-mon_tue_wed_thu_fri_1-0535-1-940GZZLUWWL", "05:41:00", "05:41:00", "940GZZLUVIC", "2"},
+		{"victoria-940GZZLUBXN-940GZZLUWWL-mon_tue_wed_thu_fri_1-0535-1-940GZZLUWWL", "06:07:00", "06:07:00", "940GZZLUWWL", "3"},
+		// Saturday's last train runs past midnight, so its times go past 24:00
+		{"victoria-940GZZLUBXN-940GZZLUWWL-sat-2530-1-940GZZLUWWL", "25:30:00", "25:30:00", "940GZZLUBXN", "1"},
+		{"victoria-940GZZLUBXN-940GZZLUWWL-sat-2530-1-940GZZLUWWL", "25:36:00", "25:36:00", "940GZZLUVIC", "2"},
+		{"victoria-940GZZLUBXN-940GZZLUWWL-sat-2530-1-940GZZLUWWL", "26:02:00", "26:02:00", "940GZZLUWWL", "3"},
+		{"victoria-940GZZLUBXN-940GZZLUWWL-sun_3-0700-1-940GZZLUWWL", "07:00:00", "07:00:00", "940GZZLUBXN", "1"},
+		{"victoria-940GZZLUBXN-940GZZLUWWL-sun_3-0700-1-940GZZLUWWL", "07:06:00", "07:06:00", "940GZZLUVIC", "2"},
+		{"victoria-940GZZLUBXN-940GZZLUWWL-sun_3-0700-1-940GZZLUWWL", "07:32:00", "07:32:00", "940GZZLUWWL", "3"},
+	})
+	expectFile(t, files, "routes.txt", [][]string{
+		{"route_id", "agency_id", "route_short_name", "route_long_name", "route_type"},
+		{"victoria", "TfL", "Victoria", "Victoria", "1"},
+	})
+}
+
+func readZip(t *testing.T, data []byte) map[string][][]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string][][]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, err := csv.NewReader(r).ReadAll()
+		r.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		result[f.Name] = rows
+	}
+	return result
+}
+
+func expectFile(t *testing.T, files map[string][][]string, name string, expected [][]string) {
+	t.Helper()
+	if got := files[name]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("%s: expected\n%q\ngot\n%q", name, expected, got)
+	}
+}
@@ -0,0 +1,151 @@
+// Package gtfsrt builds GTFS Realtime TripUpdates feeds (https://gtfs.org/realtime/reference/) from TfL arrivals.
+// Feeds are encoded as protobuf without depending on a protobuf library, or as JSON for debugging.
+package gtfsrt
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version is the GTFS Realtime version of the feeds built here.
+const Version = "2.0"
+
+// The JSON tags follow the protobuf JSON mapping of gtfs-realtime.proto, so the debug rendering
+// matches what protobuf tooling prints for the encoded feed.
+
+type FeedMessage struct {
+	Header   FeedHeader   `json:"header"`
+	Entities []FeedEntity `json:"entity,omitempty"`
+}
+
+type FeedHeader struct {
+	GTFSRealtimeVersion string         `json:"gtfsRealtimeVersion"`
+	Incrementality      Incrementality `json:"incrementality"`
+	Timestamp           uint64         `json:"timestamp,string,omitempty"`
+}
+
+type Incrementality int
+
+const (
+	FullDataset  Incrementality = 0
+	Differential Incrementality = 1
+)
+
+func (i Incrementality) MarshalJSON() ([]byte, error) {
+	switch i {
+	case FullDataset:
+		return json.Marshal("FULL_DATASET")
+	case Differential:
+		return json.Marshal("DIFFERENTIAL")
+	default:
+		return nil, fmt.Errorf("unknown incrementality %d", int(i))
+	}
+}
+
+type FeedEntity struct {
+	ID         string      `json:"id"`
+	TripUpdate *TripUpdate `json:"tripUpdate,omitempty"`
+}
+
+type TripUpdate struct {
+	Trip            TripDescriptor     `json:"trip"`
+	Vehicle         *VehicleDescriptor `json:"vehicle,omitempty"`
+	StopTimeUpdates []StopTimeUpdate   `json:"stopTimeUpdate,omitempty"`
+	Timestamp       uint64             `json:"timestamp,string,omitempty"`
+}
+
+type TripDescriptor struct {
+	TripID               string                   `json:"tripId,omitempty"`
+	RouteID              string                   `json:"routeId,omitempty"`
+	ScheduleRelationship TripScheduleRelationship `json:"scheduleRelationship,omitempty"`
+}
+
+type TripScheduleRelationship int
+
+const (
+	TripScheduled   TripScheduleRelationship = 0
+	TripAdded       TripScheduleRelationship = 1
+	TripUnscheduled TripScheduleRelationship = 2
+	TripCanceled    TripScheduleRelationship = 3
+)
+
+func (r TripScheduleRelationship) MarshalJSON() ([]byte, error) {
+	names := []string{"SCHEDULED", "ADDED", "UNSCHEDULED", "CANCELED"}
+	if r < 0 || int(r) >= len(names) {
+		return nil, fmt.Errorf("unknown trip schedule relationship %d", int(r))
+	}
+	return json.Marshal(names[r])
+}
+
+type VehicleDescriptor struct {
+	ID    string `json:"id,omitempty"`
+	Label string `json:"label,omitempty"`
+}
+
+type StopTimeUpdate struct {
+	StopID  string         `json:"stopId,omitempty"`
+	Arrival *StopTimeEvent `json:"arrival,omitempty"`
+}
+
+// StopTimeEvent.Time is in seconds since the Unix epoch.
+type StopTimeEvent struct {
+	Time int64 `json:"time,string,omitempty"`
+}
+
+// Marshal encodes the feed in the protobuf wire format of gtfs-realtime.proto.
+func (m FeedMessage) Marshal() []byte {
+	var e encoder
+	e.messageField(1, m.Header.encode)
+	for _, entity := range m.Entities {
+		e.messageField(2, entity.encode)
+	}
+	return e.buf
+}
+
+func (h FeedHeader) encode(e *encoder) {
+	e.stringField(1, h.GTFSRealtimeVersion)
+	e.uint64Field(2, uint64(h.Incrementality))
+	e.optionalUint64(3, h.Timestamp)
+}
+
+func (fe FeedEntity) encode(e *encoder) {
+	e.stringField(1, fe.ID)
+	if fe.TripUpdate != nil {
+		e.messageField(3, fe.TripUpdate.encode)
+	}
+}
+
+func (tu TripUpdate) encode(e *encoder) {
+	e.messageField(1, tu.Trip.encode)
+	for _, stu := range tu.StopTimeUpdates {
+		e.messageField(2, stu.encode)
+	}
+	if tu.Vehicle != nil {
+		e.messageField(3, tu.Vehicle.encode)
+	}
+	e.optionalUint64(4, tu.Timestamp)
+}
+
+func (td TripDescriptor) encode(e *encoder) {
+	e.optionalString(1, td.TripID)
+	if td.ScheduleRelationship != TripScheduled {
+		e.uint64Field(4, uint64(td.ScheduleRelationship))
+	}
+	e.optionalString(5, td.RouteID)
+}
+
+func (vd VehicleDescriptor) encode(e *encoder) {
+	e.optionalString(1, vd.ID)
+	e.optionalString(2, vd.Label)
+}
+
+func (stu StopTimeUpdate) encode(e *encoder) {
+	if stu.Arrival != nil {
+		e.messageField(2, stu.Arrival.encode)
+	}
+	e.optionalString(4, stu.StopID)
+}
+
+func (ste StopTimeEvent) encode(e *encoder) {
+	e.optionalInt64(2, ste.Time)
+}
@@ -0,0 +1,68 @@
+package gtfsrt
+
+// encoder writes the protobuf wire format; only the types GTFS Realtime uses are supported.
+type encoder struct {
+	buf []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		e.buf = append(e.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *encoder) tag(field, wireType int) {
+	e.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (e *encoder) uint64Field(field int, v uint64) {
+	e.tag(field, wireVarint)
+	e.varint(v)
+}
+
+// int64Field encodes int32 and int64 fields; negative values take ten bytes as protobuf requires
+func (e *encoder) int64Field(field int, v int64) {
+	e.tag(field, wireVarint)
+	e.varint(uint64(v))
+}
+
+func (e *encoder) stringField(field int, s string) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// messageField encodes the message written by body as field
+func (e *encoder) messageField(field int, body func(*encoder)) {
+	var m encoder
+	body(&m)
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(m.buf)))
+	e.buf = append(e.buf, m.buf...)
+}
+
+// optional fields are only written when set
+func (e *encoder) optionalString(field int, s string) {
+	if s != "" {
+		e.stringField(field, s)
+	}
+}
+
+func (e *encoder) optionalUint64(field int, v uint64) {
+	if v != 0 {
+		e.uint64Field(field, v)
+	}
+}
+
+func (e *encoder) optionalInt64(field int, v int64) {
+	if v != 0 {
+		e.int64Field(field, v)
+	}
+}
@@ -0,0 +1,69 @@
+package gtfsrt
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/tfl"
+)
+
+func TestEncoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		encode   func(*encoder)
+		expected string
+	}{
+		{"zero", func(e *encoder) { e.uint64Field(1, 0) }, "0800"},
+		{"one byte varint", func(e *encoder) { e.uint64Field(1, 127) }, "087f"},
+		{"two byte varint", func(e *encoder) { e.uint64Field(1, 300) }, "08ac02"},
+		{"large field number", func(e *encoder) { e.uint64Field(16, 1) }, "800101"},
+		{"negative int64", func(e *encoder) { e.int64Field(2, -1) }, "10ffffffffffffffffff01"},
+		{"string", func(e *encoder) { e.stringField(1, "ab") }, "0a026162"},
+		{"empty string", func(e *encoder) { e.stringField(1, "") }, "0a00"},
+		{"message", func(e *encoder) { e.messageField(3, func(m *encoder) { m.uint64Field(1, 150) }) }, "1a03089601"},
+		{"unset optional fields", func(e *encoder) {
+			e.optionalString(1, "")
+			e.optionalUint64(2, 0)
+			e.optionalInt64(3, 0)
+		}, ""},
+	}
+	for _, tc := range tests {
+		var e encoder
+		tc.encode(&e)
+		if got := hex.EncodeToString(e.buf); got != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestTripUpdatesMarshal(t *testing.T) {
+	la := tfl.LineArrivals{
+		LineID: "v",
+		Vehicles: []tfl.VehicleSchedule{{
+			VehicleID:   "1",
+			Destination: "B",
+			Stops:       []tfl.VehicleStop{{StationID: "S", ExpectedArrival: time.Unix(1000, 0)}},
+		}},
+	}
+	got := hex.EncodeToString(TripUpdates(la, time.Unix(100, 0)).Marshal())
+
+	expected := "" +
+		// header: version "2.0", FULL_DATASET, timestamp 100
+		"0a09" + "0a03322e30" + "1000" + "1864" +
+		// entity "1"
+		"1225" + "0a0131" +
+		// trip update
+		"1a20" +
+		// trip "v-1", ADDED, route "v"
+		"0a0a" + "0a03762d31" + "2001" + "2a0176" +
+		// stop time update: arrival at 1000 at stop "S"
+		"1208" + "120310e807" + "220153" +
+		// vehicle "1" labelled "B"
+		"1a06" + "0a0131" + "120142" +
+		// timestamp 100
+		"2064"
+	if got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
@@ -0,0 +1,45 @@
+package gtfsrt
+
+import (
+	"time"
+
+	"github.com/arunsworld/tfl"
+)
+
+// TripUpdates builds a full dataset with one TripUpdate per vehicle on the line.
+// TfL predictions aren't tied to timetabled trips, so each vehicle is reported as an added trip
+// of the GTFS route named after the line, as in the static feed of package gtfs.
+func TripUpdates(la tfl.LineArrivals, now time.Time) FeedMessage {
+	timestamp := now
+	if la.IsStale() {
+		timestamp = la.StaleAsOf
+	}
+	result := FeedMessage{
+		Header: FeedHeader{
+			GTFSRealtimeVersion: Version,
+			Incrementality:      FullDataset,
+			Timestamp:           uint64(timestamp.Unix()),
+		},
+		Entities: make([]FeedEntity, 0, len(la.Vehicles)),
+	}
+	for _, vs := range la.Vehicles {
+		tu := &TripUpdate{
+			Trip: TripDescriptor{
+				TripID:               la.LineID + "-" + vs.VehicleID,
+				RouteID:              la.LineID,
+				ScheduleRelationship: TripAdded,
+			},
+			Vehicle:         &VehicleDescriptor{ID: vs.VehicleID, Label: vs.Destination},
+			StopTimeUpdates: make([]StopTimeUpdate, 0, len(vs.Stops)),
+			Timestamp:       uint64(timestamp.Unix()),
+		}
+		for _, s := range vs.Stops {
+			tu.StopTimeUpdates = append(tu.StopTimeUpdates, StopTimeUpdate{
+				StopID:  s.StationID,
+				Arrival: &StopTimeEvent{Time: s.ExpectedArrival.Unix()},
+			})
+		}
+		result.Entities = append(result.Entities, FeedEntity{ID: vs.VehicleID, TripUpdate: tu})
+	}
+	return result
+}
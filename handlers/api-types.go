@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/arunsworld/tfl"
+)
+
+// JSON representations of the tfl types served under /api/v1.
+// Field names are part of the API contract; times are RFC 3339 and durations are whole seconds.
+
+type apiLine struct {
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	Status *apiStatus `json:"status,omitempty"`
+}
+
+type apiStatus struct {
+	Descriptions []string   `json:"descriptions"`
+	StaleAsOf    *time.Time `json:"stale_as_of,omitempty"`
+}
+
+type apiStation struct {
+	ID   string  `json:"id"`
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat,omitempty"`
+	Lon  float64 `json:"lon,omitempty"`
+}
+
+type apiRoute struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	Stations []apiStation `json:"stations"`
+}
+
+type apiArrivals struct {
+	StationID   string        `json:"station_id"`
+	StationName string        `json:"station_name"`
+	Platforms   []apiPlatform `json:"platforms"`
+	StaleAsOf   *time.Time    `json:"stale_as_of,omitempty"`
+}
+
+type apiPlatform struct {
+	Name     string       `json:"name"`
+	Arrivals []apiArrival `json:"arrivals"`
+}
+
+type apiArrival struct {
+	VehicleID            string    `json:"vehicle_id"`
+	Trackable            bool      `json:"trackable"`
+	Towards              string    `json:"towards"`
+	CurrentLocation      string    `json:"current_location"`
+	TimeToStationSeconds int64     `json:"time_to_station_seconds"`
+	ExpectedArrival      time.Time `json:"expected_arrival"`
+}
+
+type apiVehicleSchedule struct {
+	VehicleID       string           `json:"vehicle_id"`
+	Line            string           `json:"line"`
+	Destination     string           `json:"destination"`
+	CurrentLocation string           `json:"current_location"`
+	Stops           []apiVehicleStop `json:"stops"`
+	StaleAsOf       *time.Time       `json:"stale_as_of,omitempty"`
+}
+
+type apiVehicleStop struct {
+	StationID            string    `json:"station_id"`
+	StationName          string    `json:"station_name"`
+	TimeToStationSeconds int64     `json:"time_to_station_seconds"`
+	ExpectedArrival      time.Time `json:"expected_arrival"`
+}
+
+type apiDepartureTimes struct {
+	From         apiStation         `json:"from"`
+	To           apiStation         `json:"to"`
+	ScheduleName string             `json:"schedule_name"`
+	Departures   []apiDepartureTime `json:"departures"`
+}
+
+type apiDepartureTime struct {
+	Hour               string      `json:"hour"`
+	Minute             string      `json:"minute"`
+	Branch             int         `json:"branch,omitempty"`
+	Departure          *time.Time  `json:"departure,omitempty"`
+	Destination        *apiStation `json:"destination,omitempty"`
+	DestinationArrival *time.Time  `json:"destination_arrival,omitempty"`
+}
+
+type apiScheduledTimeTable struct {
+	From            apiStation         `json:"from"`
+	To              apiStation         `json:"to"`
+	Departure       apiDepartureTime   `json:"departure"`
+	Stops           []apiScheduledStop `json:"stops"`
+	CurrentLocation string             `json:"current_location,omitempty"`
+	TrackingVehicle string             `json:"tracking_vehicle,omitempty"`
+}
+
+type apiScheduledStop struct {
+	Station              apiStation `json:"station"`
+	TimeToArrivalSeconds int64      `json:"time_to_arrival_seconds"`
+	ScheduledArrival     *time.Time `json:"scheduled_arrival,omitempty"`
+	// JourneyStatus and JourneyETA are only set when tracking a vehicle
+	JourneyStatus string     `json:"journey_status,omitempty"`
+	JourneyETA    *time.Time `json:"journey_eta,omitempty"`
+}
+
+type apiErrorEnvelope struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Code              string `json:"code"`
+	Message           string `json:"message"`
+	Hint              string `json:"hint,omitempty"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+}
+
+func staleAsOf(s tfl.Staleness) *time.Time {
+	if !s.IsStale() {
+		return nil
+	}
+	t := s.StaleAsOf.UTC()
+	return &t
+}
+
+func toAPILines(lines []tfl.Line) []apiLine {
+	result := make([]apiLine, 0, len(lines))
+	for _, l := range lines {
+		result = append(result, toAPILine(l))
+	}
+	return result
+}
+
+func toAPILine(l tfl.Line) apiLine {
+	result := apiLine{ID: l.ID, Name: l.Name}
+	if l.Status.StatusDescriptions != nil {
+		result.Status = &apiStatus{
+			Descriptions: l.Status.StatusDescriptions,
+			StaleAsOf:    staleAsOf(l.Status.Staleness),
+		}
+	}
+	return result
+}
+
+func toAPIStation(s tfl.Station) apiStation {
+	return apiStation{ID: s.ID, Name: s.Name, Lat: s.Lat, Lon: s.Lon}
+}
+
+func toAPIStations(stations []tfl.Station) []apiStation {
+	result := make([]apiStation, 0, len(stations))
+	for _, s := range stations {
+		result = append(result, toAPIStation(s))
+	}
+	return result
+}
+
+func toAPIRoutes(routes []tfl.Route) []apiRoute {
+	result := make([]apiRoute, 0, len(routes))
+	for _, r := range routes {
+		result = append(result, apiRoute{ID: r.ID, Name: r.Name, Stations: toAPIStations(r.Stations)})
+	}
+	return result
+}
+
+func toAPIArrivals(a tfl.Arrivals) apiArrivals {
+	result := apiArrivals{
+		StationID:   a.StationID,
+		StationName: a.StationName,
+		Platforms:   make([]apiPlatform, 0, len(a.Platforms)),
+		StaleAsOf:   staleAsOf(a.Staleness),
+	}
+	for _, p := range a.Platforms {
+		platform := apiPlatform{Name: p.Name, Arrivals: make([]apiArrival, 0, len(p.Arrivals))}
+		for _, av := range p.Arrivals {
+			platform.Arrivals = append(platform.Arrivals, apiArrival{
+				VehicleID:            av.VehicleID,
+				Trackable:            av.CanBeTracked(),
+				Towards:              av.Towards,
+				CurrentLocation:      av.CurrentLocation,
+				TimeToStationSeconds: int64(av.TimeToStation.Seconds()),
+				ExpectedArrival:      av.ExpectedArrival.UTC(),
+			})
+		}
+		result.Platforms = append(result.Platforms, platform)
+	}
+	return result
+}
+
+func toAPIVehicleSchedule(vs tfl.VehicleSchedule) apiVehicleSchedule {
+	result := apiVehicleSchedule{
+		VehicleID:       vs.VehicleID,
+		Line:            vs.Line,
+		Destination:     vs.Destination,
+		CurrentLocation: vs.CurrentLocation,
+		Stops:           make([]apiVehicleStop, 0, len(vs.Stops)),
+		StaleAsOf:       staleAsOf(vs.Staleness),
+	}
+	for _, s := range vs.Stops {
+		result.Stops = append(result.Stops, apiVehicleStop{
+			StationID:            s.StationID,
+			StationName:          s.StationName,
+			TimeToStationSeconds: int64(s.TimeToStation.Seconds()),
+			ExpectedArrival:      s.ExpectedArrival.UTC(),
+		})
+	}
+	return result
+}
+
+func toAPIDepartureTimes(sdt tfl.ScheduledDepartureTimes) apiDepartureTimes {
+	result := apiDepartureTimes{
+		From:         toAPIStation(sdt.From),
+		To:           toAPIStation(sdt.To),
+		ScheduleName: sdt.ScheduleName,
+		Departures:   make([]apiDepartureTime, 0, len(sdt.DepartureTimes)),
+	}
+	for _, dt := range sdt.DepartureTimes {
+		result.Departures = append(result.Departures, toAPIDepartureTime(dt, sdt.ServiceDay))
+	}
+	return result
+}
+
+func toAPIDepartureTime(dt tfl.DepartureTime, day tfl.ServiceDay) apiDepartureTime {
+	result := apiDepartureTime{
+		Hour:      dt.Hour(),
+		Minute:    dt.Minute(),
+		Branch:    dt.Branch,
+		Departure: utcPtr(dt.On(day)),
+	}
+	if dt.Destination.ID != "" {
+		dest := toAPIStation(dt.Destination)
+		result.Destination = &dest
+		result.DestinationArrival = utcPtr(day.At(dt.DestinationArrival))
+	}
+	return result
+}
+
+func toAPIScheduledTimeTable(stt tfl.ScheduledTimeTable) apiScheduledTimeTable {
+	result := apiScheduledTimeTable{
+		From:            toAPIStation(stt.From),
+		To:              toAPIStation(stt.To),
+		Departure:       toAPIDepartureTime(stt.DepartureTime, stt.ServiceDay),
+		Stops:           make([]apiScheduledStop, 0, len(stt.Stops)),
+		CurrentLocation: stt.CurrentLocation,
+		TrackingVehicle: stt.TrackingVehicle,
+	}
+	for _, s := range stt.Stops {
+		stop := apiScheduledStop{
+			Station:              toAPIStation(s.Station),
+			TimeToArrivalSeconds: int64(s.TimeToArrival.Seconds()),
+			ScheduledArrival:     utcPtr(stt.ServiceDay.At(s.ETA)),
+		}
+		if stt.TrackingVehicle != "" {
+			stop.JourneyStatus = strings.ToLower(strings.TrimPrefix(s.JourneyStatus, "journey"))
+			if !s.JourneyExpectedArrival.IsZero() {
+				stop.JourneyETA = utcPtr(s.JourneyExpectedArrival)
+			}
+		}
+		result.Stops = append(result.Stops, stop)
+	}
+	return result
+}
+
+func utcPtr(t time.Time) *time.Time {
+	u := t.UTC()
+	return &u
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/gorilla/mux"
+)
+
+// registerAPIHandlers serves the data behind every HTML page as JSON under /api/v1, on the same paths
+func (h handlers) registerAPIHandlers() {
+	apiGET := h.handler.PathPrefix("/api/v1/").Methods("GET").Subrouter()
+	apiGET.HandleFunc("/lines/{mode}", func(w http.ResponseWriter, r *http.Request) {
+		mode := mux.Vars(r)["mode"]
+		lines, err := h.api.LookupLines(r.Context(), mode, true)
+		if err != nil {
+			h.writeAPIRetrievalError(w, err)
+			return
+		}
+		if len(lines) == 0 {
+			h.writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("no lines found for mode %s", mode), nil)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, toAPILines(lines))
+	})
+	apiGET.HandleFunc("/routes/{mode}/{line_id}", func(w http.ResponseWriter, r *http.Request) {
+		lineID := mux.Vars(r)["line_id"]
+		routes, err := h.api.LookupRoutes(r.Context(), lineID)
+		if err != nil {
+			h.writeAPIRetrievalError(w, err)
+			return
+		}
+		if len(routes) == 0 {
+			h.writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("no routes found for line %s", lineID), nil)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, toAPIRoutes(routes))
+	})
+	apiGET.HandleFunc("/arrivals/{mode}/{line_id}/{station_id}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		avls, err := h.api.ArrivalsForContext(r.Context(), vars["line_id"], vars["station_id"])
+		if err == nil && avls.StationID == "" {
+			err = fmt.Errorf("no arrivals found for station %s on line %s: %w", vars["station_id"], vars["line_id"], tfl.ErrNotFound)
+		}
+		if err != nil {
+			h.writeAPIRetrievalError(w, err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, toAPIArrivals(avls))
+	})
+	apiGET.HandleFunc("/vehicles/{mode}/{line_id}/{vehicle_id}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		vs, err := h.api.VehicleScheduleForContext(r.Context(), vars["line_id"], vars["vehicle_id"])
+		if err == nil && vs.VehicleID == "" {
+			err = fmt.Errorf("vehicle %s not found on line %s: %w", vars["vehicle_id"], vars["line_id"], tfl.ErrNotFound)
+		}
+		if err != nil {
+			h.writeAPIRetrievalError(w, err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, toAPIVehicleSchedule(vs))
+	})
+	apiGET.HandleFunc("/timetables/{mode}/{line_id}/{station_id}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		dest := r.URL.Query().Get("dest")
+		if dest == "" {
+			h.writeAPIError(w, http.StatusBadRequest, "bad_request", "the dest query parameter is required", nil)
+			return
+		}
+		now := time.Now()
+		sdt, err := h.api.ScheduledDepartureTimesContext(r.Context(), vars["line_id"], vars["station_id"], dest, now)
+		if err != nil {
+			h.writeAPIRetrievalError(w, err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, toAPIDepartureTimes(sdt))
+	})
+	apiGET.HandleFunc("/timetables/{mode}/{line_id}/{station_id}/{hour}/{minute}", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		queryParams := r.URL.Query()
+		dest := queryParams.Get("dest")
+		if dest == "" {
+			h.writeAPIError(w, http.StatusBadRequest, "bad_request", "the dest query parameter is required", nil)
+			return
+		}
+		depTime, err := departureTimeFrom(vars, queryParams)
+		if err != nil {
+			h.writeAPIError(w, http.StatusBadRequest, "bad_request", err.Error(), nil)
+			return
+		}
+		stt, err := h.api.ScheduledTimeTableContext(r.Context(), vars["line_id"], vars["station_id"], dest, time.Now(), depTime, queryParams.Get("v"))
+		if err != nil {
+			h.writeAPIRetrievalError(w, err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, toAPIScheduledTimeTable(stt))
+	})
+	h.registerArrivalsEventsHandler(apiGET)
+	h.registerVehicleSocketHandler(apiGET)
+	apiGET.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.writeAPIError(w, http.StatusNotFound, "not_found", fmt.Sprintf("no API endpoint at %s", r.URL.Path), nil)
+	})
+}
+
+func (h handlers) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Error("writing JSON response", "error", err)
+	}
+}
+
+// writeAPIRetrievalError maps a tfl error onto the JSON error envelope
+func (h handlers) writeAPIRetrievalError(w http.ResponseWriter, err error) {
+	h.writeAPIError(w, statusCodeFor(err), apiErrorCodeFor(err), err.Error(), err)
+}
+
+func (h handlers) writeAPIError(w http.ResponseWriter, status int, code, message string, err error) {
+	apiErr := apiError{Code: code, Message: message, Hint: errorHint(err)}
+	var tflErr *tfl.APIError
+	if errors.As(err, &tflErr) && tflErr.RetryAfter > 0 {
+		apiErr.RetryAfterSeconds = int(math.Ceil(tflErr.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", fmt.Sprint(apiErr.RetryAfterSeconds))
+	}
+	h.writeJSON(w, status, apiErrorEnvelope{Error: apiErr})
+}
+
+func apiErrorCodeFor(err error) string {
+	switch {
+	case errors.Is(err, tfl.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, tfl.ErrBadRequest):
+		return "bad_request"
+	case errors.Is(err, tfl.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, tfl.ErrUpstreamUnavailable):
+		return "upstream_unavailable"
+	case errors.Is(err, tfl.ErrDecode):
+		return "bad_upstream_response"
+	default:
+		return "internal"
+	}
+}
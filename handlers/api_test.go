@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/arunsworld/tfl/tfltest"
+	"github.com/gorilla/mux"
+)
+
+func newTestRouter(t *testing.T, api tfl.TFLAPI, opts ...Option) *mux.Router {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	router := mux.NewRouter()
+	opts = append([]Option{WithContext(ctx), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))}, opts...)
+	RegisterHandlers(router, api, fstest.MapFS{}, os.DirFS("../cmd/tfl/embed/html"), opts...)
+	return router
+}
+
+func newTestAPI(t *testing.T, srv *tfltest.Server) tfl.TFLAPI {
+	t.Helper()
+	api := tfl.New(
+		tfl.WithBaseURL(srv.URL),
+		tfl.WithRetry(1, 0, 0),
+		tfl.WithLiveDataTTL(0),
+		tfl.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+	t.Cleanup(func() { api.Close() })
+	return api
+}
+
+func TestAPIReportsUpstreamFailures(t *testing.T) {
+	srv := tfltest.NewServer()
+	defer srv.Close()
+	srv.SetError(tfltest.LinesPath("tube"), http.StatusServiceUnavailable)
+	srv.SetError(tfltest.StopPointsPath("victoria"), http.StatusInternalServerError)
+	srv.SetLines("dlr")
+	router := newTestRouter(t, newTestAPI(t, srv))
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/api/v1/lines/tube", http.StatusServiceUnavailable, "upstream_unavailable"},
+		{"/api/v1/routes/tube/victoria", http.StatusServiceUnavailable, "upstream_unavailable"},
+		{"/lines/tube", http.StatusServiceUnavailable, "upstream_unavailable"},
+		{"/routes/tube/victoria", http.StatusServiceUnavailable, "upstream_unavailable"},
+		// TfL answering with no lines is not a failure
+		{"/api/v1/lines/dlr", http.StatusNotFound, "not_found"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: expected HTTP %d, got %d: %s", tc.path, tc.status, rec.Code, rec.Body)
+			continue
+		}
+		var envelope apiErrorEnvelope
+		if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+			t.Errorf("%s: expected the JSON error envelope, got %s", tc.path, rec.Body)
+			continue
+		}
+		if envelope.Error.Code != tc.code {
+			t.Errorf("%s: expected code %s, got %s", tc.path, tc.code, envelope.Error.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lines/tube", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected the lines page to fail with HTTP 503, got %d", rec.Code)
+	}
+}
+
+func TestScheduledStopJourneyETAIsATime(t *testing.T) {
+	expected := time.Date(2026, time.October, 17, 23, 50, 30, 0, time.UTC)
+	stt := tfl.ScheduledTimeTable{
+		ServiceDay:      tfl.ServiceDayOf(expected),
+		TrackingVehicle: "201",
+		Stops: []tfl.ScheduledStop{
+			{Station: tfl.Station{ID: "940GZZLUOXC"}, JourneyStatus: "journeyOK", JourneyExpectedArrival: expected},
+			{Station: tfl.Station{ID: "940GZZLUWWL"}, JourneyStatus: "journeyNA"},
+		},
+	}
+	body, err := json.Marshal(toAPIScheduledTimeTable(stt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Stops []map[string]interface{} `json:"stops"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if got := decoded.Stops[0]["journey_eta"]; got != "2026-10-17T23:50:30Z" {
+		t.Errorf("expected an RFC 3339 journey ETA, got %v", got)
+	}
+	if _, ok := decoded.Stops[1]["journey_eta"]; ok {
+		t.Errorf("expected no journey ETA without a prediction, got %v", decoded.Stops[1]["journey_eta"])
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/gorilla/mux"
+)
+
+// sseHeartbeat keeps idle streams from being closed by proxies
+const sseHeartbeat = time.Second * 15
+
+type stationKey struct {
+	lineID, stationID string
+}
+
+func newArrivalsHub(ctx context.Context, interval time.Duration, api tfl.TFLAPI) *pollHub[stationKey, tfl.Arrivals] {
+	return newPollHub(ctx, interval, func(ctx context.Context, k stationKey) (tfl.Arrivals, error) {
+		return api.ArrivalsForContext(ctx, k.lineID, k.stationID)
+	})
+}
+
+// apiArrivalsDiff is the payload of the arrivals event stream.
+// The first event holds every arrival in Added; later ones only what changed.
+type apiArrivalsDiff struct {
+	StationID   string             `json:"station_id"`
+	StationName string             `json:"station_name"`
+	Added       []apiArrivalChange `json:"added"`
+	Removed     []apiArrivalChange `json:"removed"`
+	Changed     []apiArrivalChange `json:"changed"`
+	StaleAsOf   *time.Time         `json:"stale_as_of,omitempty"`
+}
+
+type apiArrivalChange struct {
+	// Key identifies an arrival across events
+	Key      string `json:"key"`
+	Platform string `json:"platform"`
+	// Arrival is omitted for removals
+	Arrival *apiArrival `json:"arrival,omitempty"`
+}
+
+func (d apiArrivalsDiff) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+type keyedArrival struct {
+	platform string
+	arrival  apiArrival
+}
+
+// keyArrivals keys trackable trains by vehicle ID. Untrackable trains share a placeholder vehicle ID,
+// so they are keyed by their position among the untrackable trains on the platform heading the same way:
+// a new ETA is then a change rather than a removal and an addition. When the first of them leaves,
+// the ones behind it move up a position, so they are reported changed and the last position removed.
+func keyArrivals(a apiArrivals) map[string]keyedArrival {
+	result := make(map[string]keyedArrival)
+	for _, p := range a.Platforms {
+		untrackable := make(map[string]int)
+		for _, av := range p.Arrivals {
+			key := p.Name + "|" + av.VehicleID
+			if !av.Trackable {
+				untrackable[av.Towards]++
+				key = fmt.Sprintf("%s|%s|%d", p.Name, av.Towards, untrackable[av.Towards])
+			}
+			result[key] = keyedArrival{platform: p.Name, arrival: av}
+		}
+	}
+	return result
+}
+
+func diffArrivals(prev, cur map[string]keyedArrival) (added, removed, changed []apiArrivalChange) {
+	added, removed, changed = []apiArrivalChange{}, []apiArrivalChange{}, []apiArrivalChange{}
+	for key, c := range cur {
+		av := c.arrival
+		p, ok := prev[key]
+		switch {
+		case !ok:
+			added = append(added, apiArrivalChange{Key: key, Platform: c.platform, Arrival: &av})
+		case !p.arrival.ExpectedArrival.Equal(av.ExpectedArrival) || p.arrival.CurrentLocation != av.CurrentLocation || p.arrival.Towards != av.Towards:
+			changed = append(changed, apiArrivalChange{Key: key, Platform: c.platform, Arrival: &av})
+		}
+	}
+	for key, p := range prev {
+		if _, ok := cur[key]; !ok {
+			removed = append(removed, apiArrivalChange{Key: key, Platform: p.platform})
+		}
+	}
+	for _, changes := range [][]apiArrivalChange{added, removed, changed} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	}
+	return added, removed, changed
+}
+
+func (h handlers) registerArrivalsEventsHandler(apiGET *mux.Router) {
+	apiGET.HandleFunc("/arrivals/{mode}/{line_id}/{station_id}/events", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			h.writeAPIError(w, http.StatusInternalServerError, "internal", "streaming is not supported by this server", nil)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		updates, unsubscribe := h.arrivalsHub.subscribe(stationKey{lineID: vars["line_id"], stationID: vars["station_id"]})
+		defer unsubscribe()
+		heartbeat := time.NewTicker(sseHeartbeat)
+		defer heartbeat.Stop()
+		var prev map[string]keyedArrival
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-h.ctx.Done():
+				h.writeEvent(w, "close", struct{}{})
+				flusher.Flush()
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case res := <-updates:
+				if res.err != nil {
+					h.writeEvent(w, "error", apiErrorEnvelope{Error: apiError{Code: apiErrorCodeFor(res.err), Message: res.err.Error(), Hint: errorHint(res.err)}})
+					break
+				}
+				cur := toAPIArrivals(res.value)
+				keyed := keyArrivals(cur)
+				diff := apiArrivalsDiff{StationID: cur.StationID, StationName: cur.StationName, StaleAsOf: cur.StaleAsOf}
+				diff.Added, diff.Removed, diff.Changed = diffArrivals(prev, keyed)
+				event := "diff"
+				if prev == nil {
+					event = "snapshot"
+				}
+				prev = keyed
+				if event == "diff" && diff.empty() {
+					continue
+				}
+				h.writeEvent(w, event, diff)
+			}
+			flusher.Flush()
+		}
+	})
+}
+
+func (h handlers) writeEvent(w http.ResponseWriter, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		h.logger.Error("encoding event", "event", event, "error", err)
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffArrivals(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2026, time.October, 17, 9, minute, 0, 0, time.UTC)
+	}
+	tracked := func(vehicleID, towards string, minute int) apiArrival {
+		return apiArrival{VehicleID: vehicleID, Trackable: true, Towards: towards, ExpectedArrival: at(minute)}
+	}
+	untracked := func(towards string, minute int) apiArrival {
+		return apiArrival{VehicleID: "000", Towards: towards, ExpectedArrival: at(minute)}
+	}
+	platform := func(arrivals ...apiArrival) apiArrivals {
+		return apiArrivals{Platforms: []apiPlatform{{Name: "Southbound - Platform 4", Arrivals: arrivals}}}
+	}
+	keysOf := func(changes []apiArrivalChange) []string {
+		result := []string{}
+		for _, c := range changes {
+			result = append(result, c.Key)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name                    string
+		prev, cur               apiArrivals
+		added, removed, changed []string
+	}{
+		{
+			name:    "first snapshot adds everything",
+			cur:     platform(tracked("201", "Brixton", 1), untracked("Brixton", 3)),
+			added:   []string{"Southbound - Platform 4|201", "Southbound - Platform 4|Brixton|1"},
+			removed: []string{}, changed: []string{},
+		},
+		{
+			name:  "nothing changed",
+			prev:  platform(tracked("201", "Brixton", 1), untracked("Brixton", 3)),
+			cur:   platform(tracked("201", "Brixton", 1), untracked("Brixton", 3)),
+			added: []string{}, removed: []string{}, changed: []string{},
+		},
+		{
+			name:    "a train arrives and another is predicted",
+			prev:    platform(tracked("201", "Brixton", 1), tracked("202", "Brixton", 4)),
+			cur:     platform(tracked("202", "Brixton", 4), tracked("203", "Brixton", 7)),
+			added:   []string{"Southbound - Platform 4|203"},
+			removed: []string{"Southbound - Platform 4|201"},
+			changed: []string{},
+		},
+		{
+			name:  "a tracked train is delayed",
+			prev:  platform(tracked("201", "Brixton", 1)),
+			cur:   platform(tracked("201", "Brixton", 2)),
+			added: []string{}, removed: []string{},
+			changed: []string{"Southbound - Platform 4|201"},
+		},
+		{
+			name:  "an untrackable train is delayed",
+			prev:  platform(untracked("Brixton", 3), untracked("Stockwell", 5)),
+			cur:   platform(untracked("Brixton", 4), untracked("Stockwell", 5)),
+			added: []string{}, removed: []string{},
+			changed: []string{"Southbound - Platform 4|Brixton|1"},
+		},
+		{
+			name:    "the first untrackable train leaves",
+			prev:    platform(untracked("Brixton", 3), untracked("Brixton", 6)),
+			cur:     platform(untracked("Brixton", 6)),
+			added:   []string{},
+			removed: []string{"Southbound - Platform 4|Brixton|2"},
+			changed: []string{"Southbound - Platform 4|Brixton|1"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var prev map[string]keyedArrival
+			if tc.prev.Platforms != nil {
+				prev = keyArrivals(tc.prev)
+			}
+			added, removed, changed := diffArrivals(prev, keyArrivals(tc.cur))
+			if got := keysOf(added); !reflect.DeepEqual(got, tc.added) {
+				t.Errorf("expected added %v, got %v", tc.added, got)
+			}
+			if got := keysOf(removed); !reflect.DeepEqual(got, tc.removed) {
+				t.Errorf("expected removed %v, got %v", tc.removed, got)
+			}
+			if got := keysOf(changed); !reflect.DeepEqual(got, tc.changed) {
+				t.Errorf("expected changed %v, got %v", tc.changed, got)
+			}
+			for _, c := range removed {
+				if c.Arrival != nil {
+					t.Errorf("expected removals to leave out the arrival, got %+v", c.Arrival)
+				}
+			}
+			for _, c := range append(added, changed...) {
+				if c.Arrival == nil || c.Platform != "Southbound - Platform 4" {
+					t.Errorf("expected %s to carry its arrival and platform, got %+v", c.Key, c)
+				}
+			}
+		})
+	}
+}
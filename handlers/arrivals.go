@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/arunsworld/tfl"
@@ -14,9 +15,26 @@ func (h handlers) registerArrivalsHandler() {
 		mode := vars["mode"]
 		lineID := vars["line_id"]
 		stationID := vars["station_id"]
-		avls, err := tfl.TFLAPIGlobal.ArrivalsFor(lineID, stationID)
+		repr := negotiate(w, r)
+		avls, err := h.api.ArrivalsForContext(r.Context(), lineID, stationID)
+		if repr != reprHTML {
+			if err == nil && avls.StationID == "" {
+				err = fmt.Errorf("no arrivals found for station %s on line %s: %w", stationID, lineID, tfl.ErrNotFound)
+			}
+			if err != nil {
+				h.writeDataRetrievalError(w, repr, err)
+				return
+			}
+			apiArrivals := toAPIArrivals(avls)
+			h.writeData(w, repr, apiArrivals, arrivalsTable(apiArrivals))
+			return
+		}
+		if isNotFound(err) {
+			handleStationDataNotFound(w, h.tmpls, mode, lineID, stationID)
+			return
+		}
 		if err != nil {
-			handleStationDataRetreivalError(w, h.tmpls, mode, lineID, stationID, "arrivals", false, "", "", err.Error())
+			handleStationDataRetreivalError(w, h.tmpls, mode, lineID, stationID, "arrivals", false, "", "", err)
 			return
 		}
 		if avls.StationID == "" {
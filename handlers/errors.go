@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/arunsworld/tfl"
+)
+
+// isNotFound reports whether TfL doesn't know about the requested station/vehicle.
+// TfL answers unknown IDs with either 404 or 400 depending on the endpoint.
+func isNotFound(err error) bool {
+	return errors.Is(err, tfl.ErrNotFound) || errors.Is(err, tfl.ErrBadRequest)
+}
+
+func statusCodeFor(err error) int {
+	switch {
+	case errors.Is(err, tfl.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, tfl.ErrBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, tfl.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, tfl.ErrUpstreamUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, tfl.ErrDecode):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, tfl.ErrRateLimited):
+		return "TfL is limiting how often we can ask for data. Please try again shortly."
+	case errors.Is(err, tfl.ErrUpstreamUnavailable):
+		return "TfL appears to be unavailable right now. Please try again shortly."
+	case errors.Is(err, tfl.ErrDecode):
+		return "TfL returned data we could not understand."
+	default:
+		return ""
+	}
+}
+
+// writeErrorHeader sets the status code (and Retry-After, when known) for an HTML error page
+func writeErrorHeader(w http.ResponseWriter, status int, err error) {
+	writeErrorHeaderAs(w, "text/html", status, err)
+}
+
+func writeErrorHeaderAs(w http.ResponseWriter, contentType string, status int, err error) {
+	var apiErr *tfl.APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.RetryAfter.Seconds()))))
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/arunsworld/tfl/gtfs"
+	"github.com/arunsworld/tfl/gtfsrt"
+	"github.com/gorilla/mux"
+)
+
+func (h handlers) registerGTFSHandler() {
+	h.handler.HandleFunc("/gtfs/{mode}.zip", func(w http.ResponseWriter, r *http.Request) {
+		mode := mux.Vars(r)["mode"]
+		zip, err := h.gtfsFeeds.get(r.Context(), mode, time.Now())
+		if err != nil {
+			h.logger.Error("generating GTFS feed", "mode", mode, "error", err)
+			http.Error(w, err.Error(), statusCodeFor(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="tfl-%s-gtfs.zip"`, mode))
+		w.Write(zip)
+	}).Methods("GET")
+}
+
+// gtfsFeeds holds each mode's zipped feed for the London service day it was built on.
+// Requests arriving while a feed is being built wait for that build; failed builds aren't kept.
+type gtfsFeeds struct {
+	ctx   context.Context
+	build func(ctx context.Context, mode string, now time.Time) ([]byte, error)
+
+	mu    sync.Mutex
+	feeds map[string]*gtfsFeed
+}
+
+type gtfsFeed struct {
+	day   tfl.ServiceDay
+	built chan struct{}
+	zip   []byte
+	err   error
+}
+
+func newGTFSFeeds(ctx context.Context, api tfl.TFLAPI, logger *slog.Logger) *gtfsFeeds {
+	return &gtfsFeeds{
+		ctx: ctx,
+		build: func(ctx context.Context, mode string, now time.Time) ([]byte, error) {
+			feed, err := gtfs.Generate(ctx, api, gtfs.WithModes(mode), gtfs.WithValidity(now, 0), gtfs.WithLogger(logger))
+			if err != nil {
+				return nil, err
+			}
+			var buf bytes.Buffer
+			if err := feed.WriteZip(&buf); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+		feeds: make(map[string]*gtfsFeed),
+	}
+}
+
+// get returns mode's feed for the service day of now, building it unless it is already built or being built.
+// The build carries on for later requests when ctx is done.
+func (gf *gtfsFeeds) get(ctx context.Context, mode string, now time.Time) ([]byte, error) {
+	day := tfl.ServiceDayOf(now)
+	gf.mu.Lock()
+	f, ok := gf.feeds[mode]
+	if !ok || f.day != day || f.failed() {
+		f = &gtfsFeed{day: day, built: make(chan struct{})}
+		gf.feeds[mode] = f
+		go func() {
+			defer close(f.built)
+			f.zip, f.err = gf.build(gf.ctx, mode, now)
+		}()
+	}
+	gf.mu.Unlock()
+	select {
+	case <-f.built:
+		return f.zip, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (f *gtfsFeed) failed() bool {
+	select {
+	case <-f.built:
+		return f.err != nil
+	default:
+		return false
+	}
+}
+
+func (h handlers) registerGTFSRealtimeHandlers() {
+	h.handler.HandleFunc("/gtfs-rt/{line_id}.pb", func(w http.ResponseWriter, r *http.Request) {
+		la, err := h.api.LineArrivalsContext(r.Context(), mux.Vars(r)["line_id"])
+		if err != nil {
+			http.Error(w, err.Error(), statusCodeFor(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(gtfsrt.TripUpdates(la, time.Now()).Marshal())
+	}).Methods("GET")
+	// the same feed, readable
+	h.handler.HandleFunc("/gtfs-rt/{line_id}.json", func(w http.ResponseWriter, r *http.Request) {
+		la, err := h.api.LineArrivalsContext(r.Context(), mux.Vars(r)["line_id"])
+		if err != nil {
+			h.writeAPIRetrievalError(w, err)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, gtfsrt.TripUpdates(la, time.Now()))
+	}).Methods("GET")
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/arunsworld/tfl/tfltest"
+)
+
+func TestGTFSFeedsAreBuiltOncePerServiceDay(t *testing.T) {
+	var builds atomic.Int32
+	release := make(chan struct{})
+	var failNext atomic.Bool
+	gf := &gtfsFeeds{
+		ctx: context.Background(),
+		build: func(ctx context.Context, mode string, now time.Time) ([]byte, error) {
+			builds.Add(1)
+			<-release
+			if failNext.Swap(false) {
+				return nil, tfl.ErrUpstreamUnavailable
+			}
+			return []byte(mode), nil
+		},
+		feeds: make(map[string]*gtfsFeed),
+	}
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	morning := time.Date(2026, time.October, 17, 9, 0, 0, 0, london)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if zip, err := gf.get(context.Background(), "tube", morning); err != nil || string(zip) != "tube" {
+				t.Errorf("expected the tube feed, got %q, %v", zip, err)
+			}
+		}()
+	}
+	// let every request join the build before it finishes
+	time.Sleep(time.Millisecond * 50)
+	close(release)
+	wg.Wait()
+	if n := builds.Load(); n != 1 {
+		t.Fatalf("expected concurrent requests to share one build, got %d", n)
+	}
+
+	gf.get(context.Background(), "tube", morning.Add(time.Hour*12))
+	if n := builds.Load(); n != 1 {
+		t.Errorf("expected the feed to be reused on the same service day, got %d builds", n)
+	}
+	gf.get(context.Background(), "dlr", morning)
+	if n := builds.Load(); n != 2 {
+		t.Errorf("expected each mode to have its own feed, got %d builds", n)
+	}
+
+	failNext.Store(true)
+	nextDay := morning.AddDate(0, 0, 1)
+	if _, err := gf.get(context.Background(), "tube", nextDay); !errors.Is(err, tfl.ErrUpstreamUnavailable) {
+		t.Fatalf("expected the next day's feed to be rebuilt and fail, got %v", err)
+	}
+	if zip, err := gf.get(context.Background(), "tube", nextDay); err != nil || string(zip) != "tube" {
+		t.Errorf("expected a failed build to be retried, got %q, %v", zip, err)
+	}
+	if n := builds.Load(); n != 4 {
+		t.Errorf("expected 4 builds, got %d", n)
+	}
+}
+
+func TestGTFSReportsUpstreamFailures(t *testing.T) {
+	srv := tfltest.NewServer()
+	defer srv.Close()
+	srv.SetError(tfltest.LinesPath("tube"), http.StatusServiceUnavailable)
+	srv.SetLines("dlr")
+	router := newTestRouter(t, newTestAPI(t, srv))
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/gtfs/tube.zip", http.StatusServiceUnavailable},
+		{"/gtfs/dlr.zip", http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != tc.status {
+			t.Errorf("%s: expected HTTP %d, got %d: %s", tc.path, tc.status, rec.Code, rec.Body)
+		}
+	}
+}
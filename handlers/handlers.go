@@ -1,19 +1,32 @@
 package handlers
 
 import (
+	"context"
 	"html/template"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"io/fs"
 
+	"github.com/arunsworld/tfl"
 	"github.com/gorilla/mux"
 	"github.com/unrolled/logger"
 )
 
-func RegisterHandlers(handler *mux.Router, static fs.FS, templates fs.FS) {
+func RegisterHandlers(handler *mux.Router, api tfl.TFLAPI, static fs.FS, templates fs.FS, opts ...Option) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
 	h := handlers{
 		handler: handler,
+		api:     api,
+		logger:  slog.Default(),
+		ctx:     o.ctx,
 	}
+	h.arrivalsHub = newArrivalsHub(o.ctx, o.streamInterval, api)
+	h.vehiclesHub = newVehiclesHub(o.ctx, o.streamInterval, api)
 	tmpls := template.New("").Delims("[[", "]]").Funcs(template.FuncMap{
 		"htmlSafe": func(v string) template.HTML {
 			return template.HTML(v)
@@ -22,21 +35,51 @@ func RegisterHandlers(handler *mux.Router, static fs.FS, templates fs.FS) {
 	h.tmpls = template.Must(tmpls.ParseFS(templates, "*.html"))
 
 	l := logger.New()
+	if o.logger != nil {
+		h.logger = o.logger
+		l = logger.New(logger.Options{Out: accessLogWriter{o.logger}, OutputFlags: -1})
+	}
 	handler.Use(l.Handler)
+	if o.metrics != nil {
+		h.registerMetrics(o.metrics)
+	}
 
 	h.registerStatic(static)
 	h.registerIndex()
+	h.registerHealthHandlers()
+	h.registerAPIHandlers()
+	h.registerOpenAPIHandler()
 	h.registerLinesHandler()
 	h.registerRoutesHandler()
 	h.registerArrivalsHandler()
 	h.registerVehicleHandler()
 	h.registerTimetablesHandler()
 	h.registerVehicleTrackingAgainstTimetableHandler()
+	h.gtfsFeeds = newGTFSFeeds(o.ctx, api, h.logger)
+	h.registerGTFSHandler()
+	h.registerGTFSRealtimeHandlers()
 }
 
 type handlers struct {
 	handler *mux.Router
+	api     tfl.TFLAPI
 	tmpls   *template.Template
+	logger  *slog.Logger
+	// streams
+	ctx         context.Context
+	arrivalsHub *pollHub[stationKey, tfl.Arrivals]
+	vehiclesHub *pollHub[vehicleKey, tfl.VehicleSchedule]
+	gtfsFeeds   *gtfsFeeds
+}
+
+// accessLogWriter turns each line of the unrolled/logger access log into a structured record
+type accessLogWriter struct {
+	logger *slog.Logger
+}
+
+func (a accessLogWriter) Write(p []byte) (int, error) {
+	a.logger.Info("http request", "access", strings.TrimSpace(string(p)))
+	return len(p), nil
 }
 
 func (h handlers) registerIndex() {
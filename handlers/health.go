@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/arunsworld/tfl"
+)
+
+type healthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type healthReport struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks"`
+}
+
+func (h handlers) registerHealthHandlers() {
+	h.handler.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h.writeHealthReport(w, h.api.Liveness(r.Context()))
+	}).Methods("GET")
+	h.handler.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		h.writeHealthReport(w, h.api.Readiness(r.Context()))
+	}).Methods("GET")
+}
+
+// writeHealthReport answers 200 when every check passes and 503 otherwise
+func (h handlers) writeHealthReport(w http.ResponseWriter, checks []tfl.Check) {
+	report := healthReport{Status: "ok", Checks: make([]healthCheck, 0, len(checks))}
+	for _, c := range checks {
+		if !c.OK {
+			report.Status = "fail"
+		}
+		report.Checks = append(report.Checks, healthCheck{Name: c.Name, OK: c.OK, Detail: c.Detail})
+	}
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		h.logger.Error("writing health report", "error", err)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"html/template"
 	"net/http"
 
@@ -16,12 +17,30 @@ func (h handlers) registerLinesHandler() {
 	linesGET.HandleFunc("/{mode}", func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		mode := vars["mode"]
-		lines := tfl.TFLAPIGlobal.Lines(mode, true)
+		repr := negotiate(w, r)
+		lines, err := h.api.LookupLines(r.Context(), mode, true)
+		if err != nil {
+			if repr != reprHTML {
+				h.writeDataRetrievalError(w, repr, err)
+				return
+			}
+			handleEmptyLines(w, h.tmpls, mode, err)
+			return
+		}
 		if len(lines) == 0 {
-			handleEmptyLines(w, h.tmpls, mode)
+			if repr != reprHTML {
+				h.writeDataError(w, repr, http.StatusNotFound, "not_found", fmt.Sprintf("no lines found for mode %s", mode), nil)
+				return
+			}
+			handleEmptyLines(w, h.tmpls, mode, nil)
+			return
+		}
+		if repr != reprHTML {
+			apiLines := toAPILines(lines)
+			h.writeData(w, repr, apiLines, linesTable(apiLines))
 			return
 		}
-		err := h.tmpls.ExecuteTemplate(w, "lines.html", struct {
+		err = h.tmpls.ExecuteTemplate(w, "lines.html", struct {
 			Mode  string
 			Lines [][]tfl.Line
 		}{
@@ -36,12 +55,21 @@ func (h handlers) registerLinesHandler() {
 	})
 }
 
-func handleEmptyLines(w http.ResponseWriter, tmpls *template.Template, mode string) {
-	err := tmpls.ExecuteTemplate(w, "lines-empty.html", struct {
-		Mode string
+// handleEmptyLines explains that no lines could be shown, and why when retrievalErr is set
+func handleEmptyLines(w http.ResponseWriter, tmpls *template.Template, mode string, retrievalErr error) {
+	data := struct {
+		Mode  string
+		Error string
+		Hint  string
 	}{
 		Mode: mode,
-	})
+	}
+	if retrievalErr != nil {
+		writeErrorHeader(w, statusCodeFor(retrievalErr), retrievalErr)
+		data.Error = retrievalErr.Error()
+		data.Hint = errorHint(retrievalErr)
+	}
+	err := tmpls.ExecuteTemplate(w, "lines-empty.html", data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arunsworld/tfl/metrics"
+	"github.com/gorilla/mux"
+)
+
+func (h handlers) registerMetrics(reg *metrics.Registry) {
+	requests := reg.NewCounterVec("http_requests_total",
+		"HTTP requests served by route template, method and status code.",
+		"route", "method", "code")
+	latency := reg.NewHistogramVec("http_request_duration_seconds",
+		"Latency of HTTP requests served by route template.",
+		metrics.DefaultBuckets, "route")
+
+	h.handler.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+			route := routeTemplate(r)
+			requests.WithLabelValues(route, r.Method, strconv.Itoa(sw.status)).Inc()
+			latency.WithLabelValues(route).Observe(time.Since(start).Seconds())
+		})
+	})
+	h.handler.Handle("/metrics", reg.Handler())
+}
+
+// routeTemplate keeps label cardinality bounded by using the route's template rather than the path
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "unmatched"
+	}
+	if tmpl, err := route.GetPathTemplate(); err == nil {
+		return tmpl
+	}
+	return "unmatched"
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := s.ResponseWriter.(http.Hijacker); ok {
+		s.status = http.StatusSwitchingProtocols
+		return hj.Hijack()
+	}
+	return nil, nil, fmt.Errorf("ResponseWriter does not implement the Hijacker interface")
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The HTML pages also serve their data as JSON, plain text or CSV when the Accept header prefers it.
+// JSON is the /api/v1 representation; text and CSV flatten it into a table.
+
+type representation int
+
+const (
+	reprHTML representation = iota
+	reprJSON
+	reprText
+	reprCSV
+)
+
+// representations in order of preference when the client likes several equally
+var representations = []struct {
+	mediaType string
+	repr      representation
+}{
+	{"text/html", reprHTML},
+	{"application/json", reprJSON},
+	{"text/plain", reprText},
+	{"text/csv", reprCSV},
+}
+
+// negotiate picks the representation for r, defaulting to HTML
+func negotiate(w http.ResponseWriter, r *http.Request) representation {
+	w.Header().Add("Vary", "Accept")
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return reprHTML
+	}
+	best, bestQ := reprHTML, 0.0
+	for _, rep := range representations {
+		if q := acceptQuality(accept, rep.mediaType); q > bestQ {
+			best, bestQ = rep.repr, q
+		}
+	}
+	return best
+}
+
+// acceptQuality is the q value the Accept header gives mediaType, taken from its most specific matching range
+func acceptQuality(accept, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		s := -1
+		switch {
+		case mt == mediaType:
+			s = 2
+		case mt == typ+"/*":
+			s = 1
+		case mt == "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+		rangeQ := 1.0
+		if v, ok := params["q"]; ok {
+			// ranges with a malformed q value are ignored, like malformed media types
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 || f > 1 {
+				continue
+			}
+			rangeQ = f
+		}
+		q, specificity = rangeQ, s
+	}
+	return q
+}
+
+type dataTable struct {
+	header []string
+	rows   [][]string
+}
+
+// writeData sends v as JSON, or t as text or CSV; the caller renders HTML itself
+func (h handlers) writeData(w http.ResponseWriter, repr representation, v interface{}, t dataTable) {
+	switch repr {
+	case reprJSON:
+		h.writeJSON(w, http.StatusOK, v)
+	case reprText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(t.header, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			h.logger.Error("writing text response", "error", err)
+		}
+	case reprCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		cw.Write(t.header)
+		cw.WriteAll(t.rows)
+		if err := cw.Error(); err != nil {
+			h.logger.Error("writing CSV response", "error", err)
+		}
+	}
+}
+
+// writeDataError reports an error as the JSON error envelope, or as plain text for text and CSV
+func (h handlers) writeDataError(w http.ResponseWriter, repr representation, status int, code, message string, err error) {
+	if repr == reprJSON {
+		h.writeAPIError(w, status, code, message, err)
+		return
+	}
+	writeErrorHeaderAs(w, "text/plain; charset=utf-8", status, err)
+	fmt.Fprintf(w, "%s: %s\n", code, message)
+	if hint := errorHint(err); hint != "" {
+		fmt.Fprintln(w, hint)
+	}
+}
+
+func (h handlers) writeDataRetrievalError(w http.ResponseWriter, repr representation, err error) {
+	h.writeDataError(w, repr, statusCodeFor(err), apiErrorCodeFor(err), err.Error(), err)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func linesTable(lines []apiLine) dataTable {
+	t := dataTable{header: []string{"id", "name", "status"}}
+	for _, l := range lines {
+		status := ""
+		if l.Status != nil {
+			status = strings.Join(l.Status.Descriptions, "; ")
+		}
+		t.rows = append(t.rows, []string{l.ID, l.Name, status})
+	}
+	return t
+}
+
+func routesTable(routes []apiRoute) dataTable {
+	t := dataTable{header: []string{"route_id", "route_name", "station_id", "station_name", "lat", "lon"}}
+	for _, r := range routes {
+		for _, s := range r.Stations {
+			t.rows = append(t.rows, []string{r.ID, r.Name, s.ID, s.Name,
+				strconv.FormatFloat(s.Lat, 'f', -1, 64), strconv.FormatFloat(s.Lon, 'f', -1, 64)})
+		}
+	}
+	return t
+}
+
+func arrivalsTable(a apiArrivals) dataTable {
+	t := dataTable{header: []string{"platform", "towards", "current_location", "vehicle_id", "time_to_station_seconds", "expected_arrival"}}
+	for _, p := range a.Platforms {
+		for _, av := range p.Arrivals {
+			t.rows = append(t.rows, []string{p.Name, av.Towards, av.CurrentLocation, av.VehicleID,
+				strconv.FormatInt(av.TimeToStationSeconds, 10), formatTime(&av.ExpectedArrival)})
+		}
+	}
+	return t
+}
+
+func vehicleTable(vs apiVehicleSchedule) dataTable {
+	t := dataTable{header: []string{"station_id", "station_name", "time_to_station_seconds", "expected_arrival"}}
+	for _, s := range vs.Stops {
+		t.rows = append(t.rows, []string{s.StationID, s.StationName,
+			strconv.FormatInt(s.TimeToStationSeconds, 10), formatTime(&s.ExpectedArrival)})
+	}
+	return t
+}
+
+func departuresTable(dts apiDepartureTimes) dataTable {
+	t := dataTable{header: []string{"hour", "minute", "branch", "departure", "destination_id", "destination_name", "destination_arrival"}}
+	for _, d := range dts.Departures {
+		var destID, destName string
+		if d.Destination != nil {
+			destID, destName = d.Destination.ID, d.Destination.Name
+		}
+		t.rows = append(t.rows, []string{d.Hour, d.Minute, strconv.Itoa(d.Branch), formatTime(d.Departure), destID, destName, formatTime(d.DestinationArrival)})
+	}
+	return t
+}
+
+func scheduledTimeTableTable(stt apiScheduledTimeTable) dataTable {
+	t := dataTable{header: []string{"station_id", "station_name", "time_to_arrival_seconds", "scheduled_arrival", "journey_status", "journey_eta"}}
+	for _, s := range stt.Stops {
+		t.rows = append(t.rows, []string{s.Station.ID, s.Station.Name,
+			strconv.FormatInt(s.TimeToArrivalSeconds, 10), formatTime(s.ScheduledArrival), s.JourneyStatus, formatTime(s.JourneyETA)})
+	}
+	return t
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptQuality(t *testing.T) {
+	tests := []struct {
+		accept    string
+		mediaType string
+		q         float64
+	}{
+		{"application/json", "application/json", 1},
+		{"application/json", "text/html", 0},
+		{"text/html;q=0.8", "text/html", 0.8},
+		{"TEXT/HTML", "text/html", 1},
+		// q=0 means not acceptable
+		{"text/csv;q=0, */*", "text/csv", 0},
+		{"text/*", "text/csv", 1},
+		{"text/*", "application/json", 0},
+		{"*/*;q=0.1", "application/json", 0.1},
+		// the most specific range wins, wherever it is in the header
+		{"text/*;q=0.3, text/csv;q=0.7, */*;q=0.9", "text/csv", 0.7},
+		{"text/csv;q=0.7, text/*;q=0.3", "text/csv", 0.7},
+		{"text/*;q=0.3, */*;q=0.9", "text/plain", 0.3},
+		{"text/csv;q=0.7, text/*;q=0.3", "text/plain", 0.3},
+		// malformed q values and media types are ignored
+		{"text/csv;q=high", "text/csv", 0},
+		{"text/csv;q=high, */*;q=0.2", "text/csv", 0.2},
+		{"text/csv;q=1.5, text/*;q=0.4", "text/csv", 0.4},
+		{"text/csv;q=-1", "text/csv", 0},
+		{"text/csv;;, application/json", "application/json", 1},
+		{"", "text/html", 0},
+	}
+	for _, tc := range tests {
+		if got := acceptQuality(tc.accept, tc.mediaType); got != tc.q {
+			t.Errorf("%q for %s: expected q=%v, got %v", tc.accept, tc.mediaType, tc.q, got)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected representation
+	}{
+		{"", reprHTML},
+		{"application/json", reprJSON},
+		{"text/csv", reprCSV},
+		{"text/plain", reprText},
+		// browsers
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", reprHTML},
+		{"*/*", reprHTML},
+		// HTML wins ties, then JSON
+		{"application/json, text/html", reprHTML},
+		{"text/csv, application/json", reprJSON},
+		{"text/*", reprHTML},
+		{"text/*;q=0.5, application/json", reprJSON},
+		{"text/html;q=0, text/*", reprText},
+		{"application/json;q=0.5, text/csv;q=0.9", reprCSV},
+		// nothing acceptable falls back to HTML
+		{"image/png", reprHTML},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		if got := negotiate(w, r); got != tc.expected {
+			t.Errorf("%q: expected representation %d, got %d", tc.accept, tc.expected, got)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%q: expected Vary: Accept", tc.accept)
+		}
+	}
+}
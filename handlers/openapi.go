@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// apiOperations describes every JSON route for the OpenAPI document.
+// TestOpenAPIDocumentsEveryRoute fails if a route under /api/v1 is missing here.
+var apiOperations = []apiOperation{
+	{
+		path:     "/api/v1/lines/{mode}",
+		id:       "listLines",
+		summary:  "Lines of a mode with their current status",
+		params:   []openAPIParameter{modeParam},
+		response: []apiLine{},
+	},
+	{
+		path:     "/api/v1/routes/{mode}/{line_id}",
+		id:       "listRoutes",
+		summary:  "Routes of a line with their stations in order",
+		params:   []openAPIParameter{modeParam, lineIDParam},
+		response: []apiRoute{},
+	},
+	{
+		path:     "/api/v1/arrivals/{mode}/{line_id}/{station_id}",
+		id:       "getArrivals",
+		summary:  "Live arrivals at a station by platform",
+		params:   []openAPIParameter{modeParam, lineIDParam, stationIDParam},
+		response: apiArrivals{},
+	},
+	{
+		path:        "/api/v1/arrivals/{mode}/{line_id}/{station_id}/events",
+		id:          "streamArrivals",
+		summary:     "Server-Sent Events stream of arrival changes at a station",
+		description: "A snapshot event with every arrival in added, then diff events with what was added, removed or changed. error events carry the error envelope; a close event is sent when the server shuts down.",
+		params:      []openAPIParameter{modeParam, lineIDParam, stationIDParam},
+		response:    apiArrivalsDiff{},
+		eventStream: true,
+	},
+	{
+		path:    "/api/v1/vehicles/{mode}/{line_id}/{vehicle_id}",
+		id:      "getVehicleSchedule",
+		summary: "Upcoming stops of a vehicle",
+		params: []openAPIParameter{modeParam, lineIDParam, {
+			Name: "vehicle_id", In: "path", Required: true, Description: "TfL vehicle ID, as found in arrivals", Schema: stringSchema,
+		}},
+		response: apiVehicleSchedule{},
+	},
+	{
+		path:        "/api/v1/vehicles/{mode}/{line_id}/{vehicle_id}/ws",
+		id:          "streamVehicleSchedule",
+		summary:     "WebSocket of a vehicle's location, upcoming stops and ETA changes",
+		description: "Every client watching a vehicle shares one poll of TfL. A schedule message is sent first and whenever the location or a stop changes, listing moved ETAs in eta_changes. error messages carry the error; a gone message is sent and the socket closed once TfL has not reported the vehicle for three polls in a row, and a close message when the server shuts down.",
+		params: []openAPIParameter{modeParam, lineIDParam, {
+			Name: "vehicle_id", In: "path", Required: true, Description: "TfL vehicle ID, as found in arrivals", Schema: stringSchema,
+		}},
+		response:  apiVehicleMessage{},
+		webSocket: true,
+	},
+	{
+		path:     "/api/v1/timetables/{mode}/{line_id}/{station_id}",
+		id:       "listScheduledDepartures",
+		summary:  "Today's scheduled departures from a station towards dest",
+		params:   []openAPIParameter{modeParam, lineIDParam, stationIDParam, srcParam, destParam},
+		response: apiDepartureTimes{},
+	},
+	{
+		path:    "/api/v1/timetables/{mode}/{line_id}/{station_id}/{hour}/{minute}",
+		id:      "getScheduledTimeTable",
+		summary: "Stops of one scheduled journey, optionally tracked against a live vehicle",
+		params: []openAPIParameter{modeParam, lineIDParam, stationIDParam,
+			{Name: "hour", In: "path", Required: true, Description: "departure hour as listed in the timetable; hours past 23 run into the next morning", Schema: stringSchema},
+			{Name: "minute", In: "path", Required: true, Description: "departure minute as listed in the timetable", Schema: stringSchema},
+			srcParam, destParam,
+			{Name: "branch", In: "query", Description: "branch of the journey, as listed in the departures; needed when branches share a departure time", Schema: map[string]interface{}{"type": "integer"}},
+			{Name: "terminus", In: "query", Description: "NaPTAN ID of the journey's destination, as listed in the departures", Schema: stringSchema},
+			{Name: "v", In: "query", Description: "vehicle ID to track the journey against", Schema: stringSchema},
+		},
+		response: apiScheduledTimeTable{},
+	},
+}
+
+var (
+	stringSchema   = map[string]interface{}{"type": "string"}
+	modeParam      = openAPIParameter{Name: "mode", In: "path", Required: true, Description: "TfL mode, eg. tube", Schema: stringSchema}
+	lineIDParam    = openAPIParameter{Name: "line_id", In: "path", Required: true, Description: "TfL line ID, eg. central", Schema: stringSchema}
+	stationIDParam = openAPIParameter{Name: "station_id", In: "path", Required: true, Description: "NaPTAN ID of the station", Schema: stringSchema}
+	srcParam       = openAPIParameter{Name: "src", In: "query", Description: "start of the route the station was picked from; accepted for parity with the HTML pages and ignored", Schema: stringSchema}
+	destParam      = openAPIParameter{Name: "dest", In: "query", Required: true, Description: "NaPTAN ID of the station the timetable runs towards", Schema: stringSchema}
+)
+
+type apiOperation struct {
+	path        string
+	id          string
+	summary     string
+	description string
+	params      []openAPIParameter
+	response    interface{}
+	// eventStream operations answer text/event-stream; response is the data of each event
+	eventStream bool
+	// webSocket operations upgrade the connection; response is each text message
+	webSocket bool
+}
+
+type openAPIParameter struct {
+	Name        string                 `json:"name"`
+	In          string                 `json:"in"`
+	Required    bool                   `json:"required,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+}
+
+// registerOpenAPIHandler serves the OpenAPI document, which is encoded once at startup
+func (h handlers) registerOpenAPIHandler() {
+	spec, err := openAPIDocument()
+	if err != nil {
+		h.logger.Error("unable to build OpenAPI document", "error", err)
+	}
+	h.handler.HandleFunc("/api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			h.writeAPIError(w, http.StatusInternalServerError, "internal", "the OpenAPI document is unavailable", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}).Methods("GET")
+}
+
+func openAPIDocument() ([]byte, error) {
+	spec, err := openAPISpec()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+func openAPISpec() (map[string]interface{}, error) {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	errorRef, err := schemaFor(reflect.TypeOf(apiErrorEnvelope{}), schemas)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range apiOperations {
+		schema, err := schemaFor(reflect.TypeOf(op.response), schemas)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op.path, err)
+		}
+		ok := jsonResponse("OK", schema)
+		if op.eventStream {
+			ok = contentResponse("Event stream; the data of each event is JSON", "text/event-stream", schema)
+		}
+		status := "200"
+		if op.webSocket {
+			status = "101"
+			ok = jsonResponse("Switched to WebSocket; each text message is JSON", schema)
+		}
+		get := map[string]interface{}{
+			"operationId": op.id,
+			"summary":     op.summary,
+			"parameters":  op.params,
+			"responses": map[string]interface{}{
+				status:    ok,
+				"default": jsonResponse("Error; the HTTP status is 400, 404, 429 (with Retry-After), 502, 503 or 500", errorRef),
+			},
+		}
+		if op.description != "" {
+			get["description"] = op.description
+		}
+		paths[op.path] = map[string]interface{}{"get": get}
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "TfL",
+			"version":     "v1",
+			"description": "The data behind the TfL pages. Times are RFC 3339 and durations are in seconds.",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}, nil
+}
+
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return contentResponse(description, "application/json", schema)
+}
+
+func contentResponse(description, contentType string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			contentType: map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaFor derives a JSON schema from a response type, following its json tags.
+// Structs are added to schemas as components and referenced.
+func schemaFor(t reflect.Type, schemas map[string]interface{}) (map[string]interface{}, error) {
+	switch {
+	case t == nil:
+		return nil, fmt.Errorf("no OpenAPI schema for a nil response")
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case t.Kind() == reflect.Ptr:
+		schema, err := schemaFor(t.Elem(), schemas)
+		if err != nil {
+			return nil, err
+		}
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}, nil
+		}
+		schema["nullable"] = true
+		return schema, nil
+	case t.Kind() == reflect.Slice:
+		items, err := schemaFor(t.Elem(), schemas)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}, nil
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}, nil
+	case t.Kind() == reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "api")
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		if _, done := schemas[name]; done {
+			return ref, nil
+		}
+		schemas[name] = nil // guards against recursion
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := strings.Split(f.Tag.Get("json"), ",")
+			if tag[0] == "" || tag[0] == "-" {
+				continue
+			}
+			property, err := schemaFor(f.Type, schemas)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+			}
+			properties[tag[0]] = property
+			if len(tag) == 1 || tag[1] != "omitempty" {
+				required = append(required, tag[0])
+			}
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		schemas[name] = schema
+		return ref, nil
+	default:
+		return nil, fmt.Errorf("no OpenAPI schema for %s", t)
+	}
+}
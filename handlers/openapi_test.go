@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/arunsworld/tfl/tfltest"
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	srv := tfltest.NewServer()
+	defer srv.Close()
+	router := newTestRouter(t, newTestAPI(t, srv))
+
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		documented[op.path] = true
+	}
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil || route.GetHandler() == nil || !strings.HasPrefix(tmpl, "/api/v1/") {
+			return nil
+		}
+		if !documented[tmpl] {
+			t.Errorf("API route %s is missing from the OpenAPI document", tmpl)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAPISpecEncodes(t *testing.T) {
+	doc, err := openAPIDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Paths      map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]interface{} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(doc, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Paths) != len(apiOperations) {
+		t.Errorf("expected %d paths, got %d", len(apiOperations), len(decoded.Paths))
+	}
+	for name, schema := range decoded.Components.Schemas {
+		if schema == nil {
+			t.Errorf("schema %s was never filled in", name)
+		}
+	}
+}
+
+func TestSchemaForUnknownKind(t *testing.T) {
+	type unsupported struct {
+		Callback func() `json:"callback"`
+	}
+	if _, err := schemaFor(reflect.TypeOf(unsupported{}), map[string]interface{}{}); err == nil {
+		t.Error("expected an error for a func field")
+	}
+}
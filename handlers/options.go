@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/arunsworld/tfl/metrics"
+)
+
+// Option configures RegisterHandlers.
+type Option func(*options)
+
+type options struct {
+	metrics *metrics.Registry
+	logger  *slog.Logger
+	// streams
+	ctx            context.Context
+	streamInterval time.Duration
+}
+
+func defaultOptions() options {
+	return options{
+		ctx:            context.Background(),
+		streamInterval: time.Second * 10,
+	}
+}
+
+// WithContext ends every event stream and WebSocket when ctx is done; pass the server's shutdown context.
+func WithContext(ctx context.Context) Option {
+	return func(o *options) {
+		o.ctx = ctx
+	}
+}
+
+// WithStreamInterval sets how often streamed data is polled.
+func WithStreamInterval(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.streamInterval = d
+		}
+	}
+}
+
+// WithLogger sends the access log and handler errors to l instead of stdout.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithMetrics instruments every route and serves reg on /metrics.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(o *options) {
+		o.metrics = reg
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pollHub shares one poller per key between every subscriber to it.
+// A poller runs while the key has subscribers and stops with the last of them, or when ctx is done.
+type pollHub[K comparable, V any] struct {
+	ctx      context.Context
+	interval time.Duration
+	poll     func(ctx context.Context, key K) (V, error)
+
+	mu     sync.Mutex
+	topics map[K]*pollTopic[V]
+}
+
+type pollResult[V any] struct {
+	value V
+	err   error
+}
+
+type pollTopic[V any] struct {
+	subscribers map[chan pollResult[V]]struct{}
+	last        *pollResult[V]
+	cancel      context.CancelFunc
+}
+
+func newPollHub[K comparable, V any](ctx context.Context, interval time.Duration, poll func(ctx context.Context, key K) (V, error)) *pollHub[K, V] {
+	return &pollHub[K, V]{
+		ctx:      ctx,
+		interval: interval,
+		poll:     poll,
+		topics:   make(map[K]*pollTopic[V]),
+	}
+}
+
+// subscribe returns a channel of poll results for key, starting with the latest one if there is one.
+// Slow subscribers only ever see the most recent result. Call unsubscribe when done.
+func (hub *pollHub[K, V]) subscribe(key K) (updates <-chan pollResult[V], unsubscribe func()) {
+	ch := make(chan pollResult[V], 1)
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	topic, ok := hub.topics[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(hub.ctx)
+		topic = &pollTopic[V]{
+			subscribers: make(map[chan pollResult[V]]struct{}),
+			cancel:      cancel,
+		}
+		hub.topics[key] = topic
+		go hub.run(ctx, key, topic)
+	}
+	topic.subscribers[ch] = struct{}{}
+	if topic.last != nil {
+		ch <- *topic.last
+	}
+	return ch, func() {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		delete(topic.subscribers, ch)
+		if len(topic.subscribers) == 0 && hub.topics[key] == topic {
+			topic.cancel()
+			delete(hub.topics, key)
+		}
+	}
+}
+
+func (hub *pollHub[K, V]) run(ctx context.Context, key K, topic *pollTopic[V]) {
+	ticker := time.NewTicker(hub.interval)
+	defer ticker.Stop()
+	for {
+		v, err := hub.poll(ctx, key)
+		if ctx.Err() != nil {
+			return
+		}
+		hub.publish(topic, pollResult[V]{value: v, err: err})
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (hub *pollHub[K, V]) publish(topic *pollTopic[V], res pollResult[V]) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	topic.last = &res
+	for ch := range topic.subscribers {
+		// replace an unread result rather than block on a slow subscriber
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- res
+	}
+}
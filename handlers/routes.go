@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"html/template"
 	"net/http"
 
@@ -25,8 +26,25 @@ func (h handlers) registerRoutesHandler() {
 		vars := mux.Vars(r)
 		mode := vars["mode"]
 		lineID := vars["line_id"]
-		routes := tfl.TFLAPIGlobal.Routes(lineID)
-		lineDetails := tfl.TFLAPIGlobal.LineDetails(mode, lineID)
+		repr := negotiate(w, r)
+		routes, retrievalErr := h.api.LookupRoutes(r.Context(), lineID)
+		if repr != reprHTML {
+			if retrievalErr != nil {
+				h.writeDataRetrievalError(w, repr, retrievalErr)
+				return
+			}
+			if len(routes) == 0 {
+				h.writeDataError(w, repr, http.StatusNotFound, "not_found", fmt.Sprintf("no routes found for line %s", lineID), nil)
+				return
+			}
+			apiRoutes := toAPIRoutes(routes)
+			h.writeData(w, repr, apiRoutes, routesTable(apiRoutes))
+			return
+		}
+		if retrievalErr != nil {
+			writeErrorHeader(w, statusCodeFor(retrievalErr), retrievalErr)
+		}
+		lineDetails := h.api.LineDetailsContext(r.Context(), mode, lineID)
 		// check if for arrivals or timetable
 		var nn nextNav
 		queryParams := r.URL.Query()
@@ -67,12 +85,14 @@ func (h handlers) registerRoutesHandler() {
 	})
 }
 
-func handleStationDataRetreivalError(w http.ResponseWriter, tmpls *template.Template, mode, lid, sid string, nav string, showSrcDest bool, src, dest string, errMsg string) {
+func handleStationDataRetreivalError(w http.ResponseWriter, tmpls *template.Template, mode, lid, sid string, nav string, showSrcDest bool, src, dest string, retrievalErr error) {
+	writeErrorHeader(w, statusCodeFor(retrievalErr), retrievalErr)
 	err := tmpls.ExecuteTemplate(w, "station-error.html", struct {
 		Mode        string
 		LineID      string
 		StationID   string
 		Error       string
+		Hint        string
 		Navigation  string
 		ShowSrcDest bool
 		Src, Dest   string
@@ -80,7 +100,8 @@ func handleStationDataRetreivalError(w http.ResponseWriter, tmpls *template.Temp
 		Mode:        mode,
 		LineID:      lid,
 		StationID:   sid,
-		Error:       errMsg,
+		Error:       retrievalErr.Error(),
+		Hint:        errorHint(retrievalErr),
 		Navigation:  nav,
 		ShowSrcDest: showSrcDest,
 		Src:         src,
@@ -94,6 +115,7 @@ func handleStationDataRetreivalError(w http.ResponseWriter, tmpls *template.Temp
 }
 
 func handleStationDataNotFound(w http.ResponseWriter, tmpls *template.Template, mode, lid, sid string) {
+	writeErrorHeader(w, http.StatusNotFound, nil)
 	err := tmpls.ExecuteTemplate(w, "station-not-found.html", struct {
 		Mode      string
 		LineID    string
@@ -2,8 +2,9 @@ package handlers
 
 import (
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/arunsworld/tfl"
@@ -12,13 +13,30 @@ import (
 
 func (h handlers) registerTimetablesHandler() {
 	timetablesGET := h.handler.PathPrefix("/timetables/").Methods("GET").Subrouter()
+	// registered first so the .ics suffix isn't taken as part of the station ID or minute
+	h.registerTimetableCalendarHandlers(timetablesGET)
 	timetablesGET.HandleFunc("/{mode}/{line_id}/{station_id}", func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		mode := vars["mode"]
 		lineID := vars["line_id"]
 		fromStationID := vars["station_id"]
-		// src & destination stations
 		queryParams := r.URL.Query()
+		if repr := negotiate(w, r); repr != reprHTML {
+			dest := queryParams.Get("dest")
+			if dest == "" {
+				h.writeDataError(w, repr, http.StatusBadRequest, "bad_request", "the dest query parameter is required", nil)
+				return
+			}
+			sdt, err := h.api.ScheduledDepartureTimesContext(r.Context(), lineID, fromStationID, dest, time.Now())
+			if err != nil {
+				h.writeDataRetrievalError(w, repr, err)
+				return
+			}
+			dts := toAPIDepartureTimes(sdt)
+			h.writeData(w, repr, dts, departuresTable(dts))
+			return
+		}
+		// src & destination stations
 		originStationID, ok := queryParams["src"]
 		if !ok || len(originStationID) == 0 || originStationID[0] == "" {
 			http.Redirect(w, r, fmt.Sprintf("/routes/%s/%s?timetables", mode, lineID), 302)
@@ -29,9 +47,9 @@ func (h handlers) registerTimetablesHandler() {
 			http.Redirect(w, r, fmt.Sprintf("/routes/%s/%s?timetables", mode, lineID), 302)
 			return
 		}
-		sdt, err := tfl.TFLAPIGlobal.ScheduledDepartureTimes(lineID, fromStationID, destStationID[0], time.Now().Weekday())
+		sdt, err := h.api.ScheduledDepartureTimesContext(r.Context(), lineID, fromStationID, destStationID[0], time.Now())
 		if err != nil {
-			handleStationDataRetreivalError(w, h.tmpls, mode, lineID, fromStationID, "timetables", true, originStationID[0], destStationID[0], err.Error())
+			handleStationDataRetreivalError(w, h.tmpls, mode, lineID, fromStationID, "timetables", true, originStationID[0], destStationID[0], err)
 			return
 		}
 		err = h.tmpls.ExecuteTemplate(w, "timetable-departure-times.html", struct {
@@ -60,9 +78,28 @@ func (h handlers) registerTimetablesHandler() {
 		mode := vars["mode"]
 		lineID := vars["line_id"]
 		fromStationID := vars["station_id"]
-		depTime := tfl.DepartureTime{Hour: vars["hour"], Minute: vars["minute"]}
-		// src & destination stations
 		queryParams := r.URL.Query()
+		depTime, depTimeErr := departureTimeFrom(vars, queryParams)
+		if repr := negotiate(w, r); repr != reprHTML {
+			dest := queryParams.Get("dest")
+			if dest == "" {
+				h.writeDataError(w, repr, http.StatusBadRequest, "bad_request", "the dest query parameter is required", nil)
+				return
+			}
+			if depTimeErr != nil {
+				h.writeDataError(w, repr, http.StatusBadRequest, "bad_request", depTimeErr.Error(), nil)
+				return
+			}
+			stt, err := h.api.ScheduledTimeTableContext(r.Context(), lineID, fromStationID, dest, time.Now(), depTime, queryParams.Get("v"))
+			if err != nil {
+				h.writeDataRetrievalError(w, repr, err)
+				return
+			}
+			apiSTT := toAPIScheduledTimeTable(stt)
+			h.writeData(w, repr, apiSTT, scheduledTimeTableTable(apiSTT))
+			return
+		}
+		// src & destination stations
 		originStationID, ok := queryParams["src"]
 		if !ok || len(originStationID) == 0 || originStationID[0] == "" {
 			http.Redirect(w, r, fmt.Sprintf("/routes/%s/%s?timetables", mode, lineID), 302)
@@ -73,6 +110,10 @@ func (h handlers) registerTimetablesHandler() {
 			http.Redirect(w, r, fmt.Sprintf("/routes/%s/%s?timetables", mode, lineID), 302)
 			return
 		}
+		if depTimeErr != nil {
+			http.Error(w, depTimeErr.Error(), http.StatusBadRequest)
+			return
+		}
 		vehicleID := ""
 		vehicleTracking := false
 		_vid, ok := queryParams["v"]
@@ -80,9 +121,9 @@ func (h handlers) registerTimetablesHandler() {
 			vehicleID = _vid[0]
 			vehicleTracking = true
 		}
-		stt, err := tfl.TFLAPIGlobal.ScheduledTimeTable(lineID, fromStationID, destStationID[0], time.Now().Weekday(), depTime, vehicleID)
+		stt, err := h.api.ScheduledTimeTableContext(r.Context(), lineID, fromStationID, destStationID[0], time.Now(), depTime, vehicleID)
 		if err != nil {
-			handleStationDataRetreivalError(w, h.tmpls, mode, lineID, fromStationID, "timetables", true, originStationID[0], destStationID[0], err.Error())
+			handleStationDataRetreivalError(w, h.tmpls, mode, lineID, fromStationID, "timetables", true, originStationID[0], destStationID[0], err)
 			return
 		}
 		err = h.tmpls.ExecuteTemplate(w, "timetable-schedule.html", struct {
@@ -103,7 +144,7 @@ func (h handlers) registerTimetablesHandler() {
 			VehicleTracking:    vehicleTracking,
 		})
 		if err != nil {
-			log.Println(err)
+			h.logger.Error("rendering timetable", "line", lineID, "station", fromStationID, "error", err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -111,6 +152,93 @@ func (h handlers) registerTimetablesHandler() {
 	})
 }
 
+const (
+	defaultCalendarDepartures = 10
+	maxCalendarDepartures     = 100
+)
+
+func (h handlers) registerTimetableCalendarHandlers(timetablesGET *mux.Router) {
+	timetablesGET.HandleFunc("/{mode}/{line_id}/{station_id}.ics", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		queryParams := r.URL.Query()
+		dest := queryParams.Get("dest")
+		if dest == "" {
+			http.Error(w, "the dest query parameter is required", http.StatusBadRequest)
+			return
+		}
+		n := defaultCalendarDepartures
+		if v := queryParams.Get("n"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed < 1 || parsed > maxCalendarDepartures {
+				http.Error(w, fmt.Sprintf("n must be between 1 and %d", maxCalendarDepartures), http.StatusBadRequest)
+				return
+			}
+			n = parsed
+		}
+		now := time.Now()
+		sdt, err := h.api.ScheduledDepartureTimesContext(r.Context(), vars["line_id"], vars["station_id"], dest, now)
+		if err != nil {
+			http.Error(w, err.Error(), statusCodeFor(err))
+			return
+		}
+		writeCalendar(w, fmt.Sprintf("departures-%s-%s.ics", vars["station_id"], dest), sdt.ICS(now, n))
+	})
+	timetablesGET.HandleFunc("/{mode}/{line_id}/{station_id}/{hour}/{minute}.ics", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		dest := r.URL.Query().Get("dest")
+		if dest == "" {
+			http.Error(w, "the dest query parameter is required", http.StatusBadRequest)
+			return
+		}
+		depTime, err := departureTimeFrom(vars, r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		stt, err := h.api.ScheduledTimeTableContext(r.Context(), vars["line_id"], vars["station_id"], dest, time.Now(), depTime, "")
+		if err != nil {
+			http.Error(w, err.Error(), statusCodeFor(err))
+			return
+		}
+		writeCalendar(w, fmt.Sprintf("journey-%s-%s%s.ics", vars["station_id"], vars["hour"], vars["minute"]), stt.ICS())
+	})
+}
+
+// departureTimeFrom reads the departure from the hour and minute path variables, narrowed to a branch
+// and the journey's terminus by the optional branch and terminus query parameters
+func departureTimeFrom(vars map[string]string, queryParams url.Values) (tfl.DepartureTime, error) {
+	depTime, err := tfl.NewDepartureTime(vars["hour"], vars["minute"])
+	if err != nil {
+		return tfl.DepartureTime{}, err
+	}
+	if v := queryParams.Get("branch"); v != "" {
+		branch, err := strconv.Atoi(v)
+		if err != nil || branch < 1 {
+			return tfl.DepartureTime{}, fmt.Errorf("invalid branch %q", v)
+		}
+		depTime.Branch = branch
+	}
+	depTime.Destination.ID = queryParams.Get("terminus")
+	return depTime, nil
+}
+
+// journeyQuery keeps the branch and terminus of a journey in links to it
+func journeyQuery(queryParams url.Values) string {
+	result := ""
+	for _, k := range []string{"branch", "terminus"} {
+		if v := queryParams.Get(k); v != "" {
+			result += "&" + k + "=" + url.QueryEscape(v)
+		}
+	}
+	return result
+}
+
+func writeCalendar(w http.ResponseWriter, filename string, ics []byte) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Write(ics)
+}
+
 func (h handlers) registerVehicleTrackingAgainstTimetableHandler() {
 	trackerPOST := h.handler.PathPrefix("/track/").Methods("POST").Subrouter()
 	trackerPOST.HandleFunc("/{mode}/{line_id}/{station_id}/{src_station}/{dest_station}/{hour}/{minute}", func(w http.ResponseWriter, r *http.Request) {
@@ -122,15 +250,16 @@ func (h handlers) registerVehicleTrackingAgainstTimetableHandler() {
 		minute := vars["minute"]
 		srcStationID := vars["src_station"]
 		destStationID := vars["dest_station"]
+		journey := journeyQuery(r.URL.Query())
 		if err := r.ParseForm(); err != nil {
-			http.Redirect(w, r, fmt.Sprintf("/timetables/%s/%s/%s/%s/%s?src=%s&dest=%s", mode, lineID, fromStationID, hour, minute, srcStationID, destStationID), 302)
+			http.Redirect(w, r, fmt.Sprintf("/timetables/%s/%s/%s/%s/%s?src=%s&dest=%s%s", mode, lineID, fromStationID, hour, minute, srcStationID, destStationID, journey), 302)
 			return
 		}
 		vehicleID := r.FormValue("vehicleID")
 		if vehicleID == "" {
-			http.Redirect(w, r, fmt.Sprintf("/timetables/%s/%s/%s/%s/%s?src=%s&dest=%s", mode, lineID, fromStationID, hour, minute, srcStationID, destStationID), 302)
+			http.Redirect(w, r, fmt.Sprintf("/timetables/%s/%s/%s/%s/%s?src=%s&dest=%s%s", mode, lineID, fromStationID, hour, minute, srcStationID, destStationID, journey), 302)
 			return
 		}
-		http.Redirect(w, r, fmt.Sprintf("/timetables/%s/%s/%s/%s/%s?src=%s&dest=%s&v=%s", mode, lineID, fromStationID, hour, minute, srcStationID, destStationID, vehicleID), 302)
+		http.Redirect(w, r, fmt.Sprintf("/timetables/%s/%s/%s/%s/%s?src=%s&dest=%s&v=%s%s", mode, lineID, fromStationID, hour, minute, srcStationID, destStationID, vehicleID, journey), 302)
 	})
 }
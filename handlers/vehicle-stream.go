@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/gorilla/mux"
+)
+
+// wsPingInterval keeps idle sockets from being closed by proxies
+const wsPingInterval = time.Second * 30
+
+// vehicleGoneAfter is how many polls in a row must miss a vehicle before it is reported gone;
+// TfL sometimes drops a vehicle from a single response
+const vehicleGoneAfter = 3
+
+type vehicleKey struct {
+	lineID, vehicleID string
+}
+
+func newVehiclesHub(ctx context.Context, interval time.Duration, api tfl.TFLAPI) *pollHub[vehicleKey, tfl.VehicleSchedule] {
+	return newPollHub(ctx, interval, func(ctx context.Context, k vehicleKey) (tfl.VehicleSchedule, error) {
+		return api.VehicleScheduleForContext(ctx, k.lineID, k.vehicleID)
+	})
+}
+
+// apiVehicleMessage is a message of the vehicle WebSocket.
+// schedule messages hold the whole schedule and are only sent when it changes;
+// gone and close messages are followed by the socket closing.
+type apiVehicleMessage struct {
+	Type    string              `json:"type"`
+	Vehicle *apiVehicleSchedule `json:"vehicle,omitempty"`
+	// ETAChanges lists stops whose expected arrival moved since the previous schedule message
+	ETAChanges []apiETAChange `json:"eta_changes,omitempty"`
+	Error      *apiError      `json:"error,omitempty"`
+}
+
+type apiETAChange struct {
+	StationID       string    `json:"station_id"`
+	StationName     string    `json:"station_name"`
+	Previous        time.Time `json:"previous"`
+	ExpectedArrival time.Time `json:"expected_arrival"`
+}
+
+func etaChanges(prev, cur apiVehicleSchedule) []apiETAChange {
+	previous := make(map[string]time.Time, len(prev.Stops))
+	for _, s := range prev.Stops {
+		previous[s.StationID] = s.ExpectedArrival
+	}
+	var result []apiETAChange
+	for _, s := range cur.Stops {
+		p, ok := previous[s.StationID]
+		if ok && !p.Equal(s.ExpectedArrival) {
+			result = append(result, apiETAChange{StationID: s.StationID, StationName: s.StationName, Previous: p, ExpectedArrival: s.ExpectedArrival})
+		}
+	}
+	return result
+}
+
+func vehicleScheduleChanged(prev, cur apiVehicleSchedule) bool {
+	if prev.CurrentLocation != cur.CurrentLocation || prev.Destination != cur.Destination || len(prev.Stops) != len(cur.Stops) {
+		return true
+	}
+	if (prev.StaleAsOf == nil) != (cur.StaleAsOf == nil) {
+		return true
+	}
+	for i := range prev.Stops {
+		if prev.Stops[i].StationID != cur.Stops[i].StationID || !prev.Stops[i].ExpectedArrival.Equal(cur.Stops[i].ExpectedArrival) {
+			return true
+		}
+	}
+	return false
+}
+
+// vehicleGone reports whether TfL no longer knows the vehicle
+func vehicleGone(res pollResult[tfl.VehicleSchedule]) bool {
+	if res.err != nil {
+		return errors.Is(res.err, tfl.ErrNotFound)
+	}
+	return res.value.VehicleID == ""
+}
+
+func (h handlers) registerVehicleSocketHandler(apiGET *mux.Router) {
+	apiGET.HandleFunc("/vehicles/{mode}/{line_id}/{vehicle_id}/ws", func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		conn, err := upgradeWebSocket(w, r)
+		if errors.Is(err, errCrossOrigin) {
+			h.writeAPIError(w, http.StatusForbidden, "forbidden", err.Error(), nil)
+			return
+		}
+		if err != nil {
+			h.writeAPIError(w, http.StatusBadRequest, "bad_request", err.Error(), nil)
+			return
+		}
+		closed := conn.readLoop()
+
+		updates, unsubscribe := h.vehiclesHub.subscribe(vehicleKey{lineID: vars["line_id"], vehicleID: vars["vehicle_id"]})
+		defer unsubscribe()
+		ping := time.NewTicker(wsPingInterval)
+		defer ping.Stop()
+		var prev *apiVehicleSchedule
+		missing := 0
+		for {
+			var err error
+			select {
+			case <-closed:
+				return
+			case <-h.ctx.Done():
+				h.writeMessage(conn, apiVehicleMessage{Type: "close"})
+				conn.close(wsCloseGoingAway, "server shutting down")
+				return
+			case <-ping.C:
+				err = conn.ping()
+			case res := <-updates:
+				if vehicleGone(res) {
+					missing++
+				} else {
+					missing = 0
+				}
+				switch {
+				case missing >= vehicleGoneAfter:
+					h.writeMessage(conn, apiVehicleMessage{Type: "gone"})
+					conn.close(wsCloseNormal, "vehicle gone")
+					return
+				case missing > 0:
+					continue
+				case res.err != nil:
+					err = h.writeMessage(conn, apiVehicleMessage{Type: "error", Error: &apiError{Code: apiErrorCodeFor(res.err), Message: res.err.Error(), Hint: errorHint(res.err)}})
+				default:
+					cur := toAPIVehicleSchedule(res.value)
+					if prev != nil && !vehicleScheduleChanged(*prev, cur) {
+						continue
+					}
+					msg := apiVehicleMessage{Type: "schedule", Vehicle: &cur}
+					if prev != nil {
+						msg.ETAChanges = etaChanges(*prev, cur)
+					}
+					prev = &cur
+					err = h.writeMessage(conn, msg)
+				}
+			}
+			if err != nil {
+				conn.close(wsCloseGoingAway, "")
+				return
+			}
+		}
+	})
+}
+
+func (h handlers) writeMessage(conn *wsConn, msg apiVehicleMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		h.logger.Error("encoding message", "type", msg.Type, "error", err)
+		return nil
+	}
+	return conn.writeText(data)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/tfl/tfltest"
+)
+
+const testVehicleSocketPath = "/api/v1/vehicles/tube/victoria/201/ws"
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		same   bool
+	}{
+		{"", true},
+		{"http://tfl.example", true},
+		{"https://TFL.example", true},
+		{"http://tfl.example:8080", false},
+		{"http://evil.example", false},
+		{"null", false},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://tfl.example/", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		if got := sameOrigin(r); got != tc.same {
+			t.Errorf("origin %q: expected %v, got %v", tc.origin, tc.same, got)
+		}
+	}
+}
+
+func TestVehicleSocketRefusesOtherOrigins(t *testing.T) {
+	srv := tfltest.NewServer()
+	defer srv.Close()
+	router := newTestRouter(t, newTestAPI(t, srv))
+
+	req := httptest.NewRequest(http.MethodGet, testVehicleSocketPath, nil)
+	setUpgradeHeaders(req.Header)
+	req.Header.Set("Origin", "http://evil.example")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected HTTP 403 for another origin, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestVehicleSocketToleratesBriefDisappearance(t *testing.T) {
+	srv := tfltest.NewServer()
+	defer srv.Close()
+	srv.SetVehicleArrivals("201", tfltest.Arrival{
+		VehicleID: "201", NaptanID: "940GZZLUOXC", StationName: "Oxford Circus Underground Station", LineID: "victoria", LineName: "Victoria",
+		TimeToStation: 60, ExpectedArrival: time.Now().Add(time.Minute).UTC().Format(time.RFC3339),
+	})
+	// TfL forgets the vehicle for two polls before it shows up again
+	srv.FailNext(tfltest.VehicleArrivalsPath("201"), vehicleGoneAfter-1, http.StatusNotFound)
+	ts := httptest.NewServer(newTestRouter(t, newTestAPI(t, srv), WithStreamInterval(time.Millisecond*20)))
+	defer ts.Close()
+
+	conn, r := dialTestSocket(t, ts.URL+testVehicleSocketPath)
+	defer conn.Close()
+	if msg := readTestMessage(t, conn, r); msg.Type != "schedule" {
+		t.Fatalf("expected the schedule once the vehicle reappears, got %+v", msg)
+	}
+
+	srv.SetError(tfltest.VehicleArrivalsPath("201"), http.StatusNotFound)
+	if msg := readTestMessage(t, conn, r); msg.Type != "gone" {
+		t.Fatalf("expected the vehicle to be reported gone, got %+v", msg)
+	}
+}
+
+func setUpgradeHeaders(h http.Header) {
+	h.Set("Connection", "Upgrade")
+	h.Set("Upgrade", "websocket")
+	h.Set("Sec-WebSocket-Version", "13")
+	h.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+}
+
+func dialTestSocket(t *testing.T, rawURL string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setUpgradeHeaders(req.Header)
+	req.Header.Set("Origin", "http://"+u.Host)
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected the upgrade to succeed, got HTTP %d", resp.StatusCode)
+	}
+	return conn, r
+}
+
+// readTestMessage returns the next text message, skipping pings
+func readTestMessage(t *testing.T, conn net.Conn, r *bufio.Reader) apiVehicleMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(r, header); err != nil {
+			t.Fatal(err)
+		}
+		n := uint64(header[1] & 0x7F)
+		switch n {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				t.Fatal(err)
+			}
+			n = uint64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				t.Fatal(err)
+			}
+			n = binary.BigEndian.Uint64(ext)
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			t.Fatal(err)
+		}
+		if header[0]&0x0F != wsOpText {
+			continue
+		}
+		var msg apiVehicleMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+}
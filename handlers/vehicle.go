@@ -23,9 +23,26 @@ func (h handlers) registerVehicleHandler() {
 		mode := vars["mode"]
 		lineID := vars["line_id"]
 		vehicleID := vars["vehicle_id"]
-		vs, err := tfl.TFLAPIGlobal.VehicleScheduleFor(lineID, vehicleID)
+		repr := negotiate(w, r)
+		vs, err := h.api.VehicleScheduleForContext(r.Context(), lineID, vehicleID)
+		if repr != reprHTML {
+			if err == nil && vs.VehicleID == "" {
+				err = fmt.Errorf("vehicle %s not found on line %s: %w", vehicleID, lineID, tfl.ErrNotFound)
+			}
+			if err != nil {
+				h.writeDataRetrievalError(w, repr, err)
+				return
+			}
+			apiVS := toAPIVehicleSchedule(vs)
+			h.writeData(w, repr, apiVS, vehicleTable(apiVS))
+			return
+		}
+		if isNotFound(err) {
+			handleVehicleNotFound(w, h.tmpls, lineID, vehicleID)
+			return
+		}
 		if err != nil {
-			handleVehicleDataRetreivalError(w, h.tmpls, lineID, vehicleID, err.Error())
+			handleVehicleDataRetreivalError(w, h.tmpls, lineID, vehicleID, err)
 			return
 		}
 		if vs.VehicleID == "" {
@@ -49,15 +66,18 @@ func (h handlers) registerVehicleHandler() {
 	})
 }
 
-func handleVehicleDataRetreivalError(w http.ResponseWriter, tmpls *template.Template, lid, vid string, errMsg string) {
+func handleVehicleDataRetreivalError(w http.ResponseWriter, tmpls *template.Template, lid, vid string, retrievalErr error) {
+	writeErrorHeader(w, statusCodeFor(retrievalErr), retrievalErr)
 	err := tmpls.ExecuteTemplate(w, "vehicle-error.html", struct {
 		LineID    string
 		VehicleID string
 		Error     string
+		Hint      string
 	}{
 		LineID:    lid,
 		VehicleID: vid,
-		Error:     errMsg,
+		Error:     retrievalErr.Error(),
+		Hint:      errorHint(retrievalErr),
 	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -67,6 +87,7 @@ func handleVehicleDataRetreivalError(w http.ResponseWriter, tmpls *template.Temp
 }
 
 func handleVehicleNotFound(w http.ResponseWriter, tmpls *template.Template, lid, vid string) {
+	writeErrorHeader(w, http.StatusNotFound, nil)
 	err := tmpls.ExecuteTemplate(w, "vehicle-not-found.html", struct {
 		VehicleID string
 		LineID    string
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal RFC 6455 server: enough to push JSON text messages and honour the client's
+// close and ping frames. Messages from the client are read and discarded.
+
+const (
+	wsGUID               = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsWriteTimeout       = time.Second * 10
+	wsMaxIncomingFrame   = 1 << 16
+	wsOpContinuation     = 0x0
+	wsOpText             = 0x1
+	wsOpBinary           = 0x2
+	wsOpClose            = 0x8
+	wsOpPing             = 0x9
+	wsOpPong             = 0xA
+	wsCloseNormal        = 1000
+	wsCloseGoingAway     = 1001
+	wsCloseProtocolErr   = 1002
+	wsCloseMessageTooBig = 1009
+)
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	// writes come from both the handler and the read loop answering pings
+	mu     sync.Mutex
+	closed bool
+}
+
+// errCrossOrigin refuses browsers connecting from a page served by another site
+var errCrossOrigin = errors.New("websocket connections from other origins are not allowed")
+
+// sameOrigin reports whether the page that opened the socket was served by this host.
+// Only browsers send Origin, so requests without one are allowed.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket completes the opening handshake; on error nothing has been written to w
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if r.Method != http.MethodGet {
+		return nil, fmt.Errorf("websocket upgrade requires GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, fmt.Errorf("this endpoint only speaks WebSocket")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("unsupported WebSocket version %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+	if !sameOrigin(r) {
+		return nil, errCrossOrigin
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("websocket is not supported by this server")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	c := &wsConn{conn: conn, rw: rw}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+func (c *wsConn) writeText(data []byte) error {
+	return c.writeFrame(wsOpText, data)
+}
+
+func (c *wsConn) ping() error {
+	return c.writeFrame(wsOpPing, nil)
+}
+
+// close sends a close frame and closes the connection without waiting for the client's reply
+func (c *wsConn) close(code uint16, reason string) {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	c.writeFrame(wsOpClose, append(payload, reason...))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		c.conn.Close()
+	}
+}
+
+// readLoop handles frames from the client until it closes or the connection fails.
+// The returned channel is closed when that happens.
+func (c *wsConn) readLoop() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			opcode, payload, err := c.readFrame()
+			var tooBig errFrameTooBig
+			switch {
+			case errors.As(err, &tooBig):
+				c.close(wsCloseMessageTooBig, "message too big")
+				return
+			case errors.Is(err, errUnmaskedFrame):
+				c.close(wsCloseProtocolErr, "client frames must be masked")
+				return
+			case err != nil:
+				c.close(wsCloseGoingAway, "")
+				return
+			}
+			switch opcode {
+			case wsOpClose:
+				c.close(wsCloseNormal, "")
+				return
+			case wsOpPing:
+				c.writeFrame(wsOpPong, payload)
+			}
+		}
+	}()
+	return done
+}
+
+var errUnmaskedFrame = errors.New("unmasked client frame")
+
+type errFrameTooBig uint64
+
+func (e errFrameTooBig) Error() string {
+	return fmt.Sprintf("frame of %d bytes is too big", uint64(e))
+}
+
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := head[0] & 0x0F
+	if head[1]&0x80 == 0 {
+		return 0, nil, errUnmaskedFrame
+	}
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxIncomingFrame {
+		return 0, nil, errFrameTooBig(length)
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
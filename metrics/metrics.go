@@ -0,0 +1,357 @@
+// Package metrics is a minimal, dependency free implementation of counters, gauges and histograms
+// exposed in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit latencies in seconds, from a millisecond cache hit to a slow TfL call.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics and renders them for scraping.
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[c.name()]; exists {
+		panic(fmt.Sprintf("metrics: %s registered twice", c.name()))
+	}
+	r.collectors[c.name()] = c
+}
+
+// WriteTo writes every metric in the Prometheus text format, sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for n := range r.collectors {
+		names = append(names, n)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, n := range names {
+		collectors = append(collectors, r.collectors[n])
+	}
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		c.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler serves the registry for Prometheus to scrape.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// vec is the shared bookkeeping of a metric family keyed by label values
+type vec[M any] struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+
+	mu      sync.Mutex
+	members map[string]*M
+	values  map[string][]string
+	create  func() *M
+}
+
+// newVec creates the single member of an unlabelled metric up front so it's exported as zero
+func newVec[M any](name, help, kind string, labels []string, create func() *M) *vec[M] {
+	v := &vec[M]{
+		metricName: name,
+		help:       help,
+		kind:       kind,
+		labels:     labels,
+		members:    make(map[string]*M),
+		values:     make(map[string][]string),
+		create:     create,
+	}
+	if len(labels) == 0 {
+		v.with(nil)
+	}
+	return v
+}
+
+func (v *vec[M]) name() string {
+	return v.metricName
+}
+
+func (v *vec[M]) with(labelValues []string) *M {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	m, ok := v.members[key]
+	if !ok {
+		m = v.create()
+		v.members[key] = m
+		v.values[key] = append([]string(nil), labelValues...)
+	}
+	return m
+}
+
+// each calls fn for every member in a stable order
+func (v *vec[M]) each(fn func(labelValues []string, m *M)) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.members))
+	for k := range v.members {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	type member struct {
+		values []string
+		m      *M
+	}
+	members := make([]member, 0, len(keys))
+	for _, k := range keys {
+		members = append(members, member{values: v.values[k], m: v.members[k]})
+	}
+	v.mu.Unlock()
+	for _, mb := range members {
+		fn(mb.values, mb.m)
+	}
+}
+
+func (v *vec[M]) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.metricName, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.metricName, v.kind)
+}
+
+// CounterVec is a family of monotonically increasing counters.
+type CounterVec struct {
+	*vec[Counter]
+}
+
+type Counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.mu.Lock()
+	c.value += v
+	c.mu.Unlock()
+}
+
+func (c *Counter) get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	cv := &CounterVec{newVec(name, help, "counter", labels, func() *Counter { return &Counter{} })}
+	r.register(cv)
+	return cv
+}
+
+func (cv *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	return cv.with(labelValues)
+}
+
+func (cv *CounterVec) write(w *bufio.Writer) {
+	cv.writeHeader(w)
+	cv.each(func(values []string, c *Counter) {
+		writeSample(w, cv.metricName, cv.labels, values, "", "", c.get())
+	})
+}
+
+// GaugeVec is a family of values that can go up and down.
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+type Gauge struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+func (g *Gauge) get() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	gv := &GaugeVec{newVec(name, help, "gauge", labels, func() *Gauge { return &Gauge{} })}
+	r.register(gv)
+	return gv
+}
+
+func (gv *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	return gv.with(labelValues)
+}
+
+func (gv *GaugeVec) write(w *bufio.Writer) {
+	gv.writeHeader(w)
+	gv.each(func(values []string, g *Gauge) {
+		writeSample(w, gv.metricName, gv.labels, values, "", "", g.get())
+	})
+}
+
+// HistogramVec is a family of histograms sharing bucket boundaries.
+type HistogramVec struct {
+	*vec[Histogram]
+	buckets []float64
+}
+
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	hv := &HistogramVec{
+		vec: newVec(name, help, "histogram", labels, func() *Histogram {
+			return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+		}),
+		buckets: buckets,
+	}
+	r.register(hv)
+	return hv
+}
+
+func (hv *HistogramVec) WithLabelValues(labelValues ...string) *Histogram {
+	return hv.with(labelValues)
+}
+
+func (hv *HistogramVec) write(w *bufio.Writer) {
+	hv.writeHeader(w)
+	hv.each(func(values []string, h *Histogram) {
+		h.mu.Lock()
+		counts := append([]uint64(nil), h.counts...)
+		sum, count := h.sum, h.count
+		h.mu.Unlock()
+		for i, upper := range hv.buckets {
+			writeSample(w, hv.metricName+"_bucket", hv.labels, values, "le", formatFloat(upper), float64(counts[i]))
+		}
+		writeSample(w, hv.metricName+"_bucket", hv.labels, values, "le", "+Inf", float64(count))
+		writeSample(w, hv.metricName+"_sum", hv.labels, values, "", "", sum)
+		writeSample(w, hv.metricName+"_count", hv.labels, values, "", "", float64(count))
+	})
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabelValue(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(v string) string {
+	return labelValueEscaper.Replace(v)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(v string) string {
+	return helpEscaper.Replace(v)
+}
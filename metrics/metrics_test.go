@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("http_requests_total", "HTTP requests by route and status code.", "route", "code")
+	requests.WithLabelValues("/lines/{mode}", "200").Add(3)
+	requests.WithLabelValues("/lines/{mode}", "404").Inc()
+	requests.WithLabelValues(`C:\path "quoted"`+"\nnext", "500").Inc()
+	up := r.NewGaugeVec("tfl_up", "1 when TfL answered the last request.\nA backslash \\ is escaped.")
+	up.WithLabelValues().Set(1)
+	latency := r.NewHistogramVec("tfl_latency_seconds", "TfL latency.", []float64{1, 0.1}, "endpoint")
+	latency.WithLabelValues("arrivals").Observe(0.05)
+	latency.WithLabelValues("arrivals").Observe(0.5)
+	latency.WithLabelValues("arrivals").Observe(2)
+	r.NewHistogramVec("tfl_wait_seconds", "Time spent waiting.", []float64{0.5})
+
+	expected := `# HELP http_requests_total HTTP requests by route and status code.
+# TYPE http_requests_total counter
+http_requests_total{route="/lines/{mode}",code="200"} 3
+http_requests_total{route="/lines/{mode}",code="404"} 1
+http_requests_total{route="C:\\path \"quoted\"\nnext",code="500"} 1
+# HELP tfl_latency_seconds TfL latency.
+# TYPE tfl_latency_seconds histogram
+tfl_latency_seconds_bucket{endpoint="arrivals",le="0.1"} 1
+tfl_latency_seconds_bucket{endpoint="arrivals",le="1"} 2
+tfl_latency_seconds_bucket{endpoint="arrivals",le="+Inf"} 3
+tfl_latency_seconds_sum{endpoint="arrivals"} 2.55
+tfl_latency_seconds_count{endpoint="arrivals"} 3
+# HELP tfl_up 1 when TfL answered the last request.\nA backslash \\ is escaped.
+# TYPE tfl_up gauge
+tfl_up 1
+# HELP tfl_wait_seconds Time spent waiting.
+# TYPE tfl_wait_seconds histogram
+tfl_wait_seconds_bucket{le="0.5"} 0
+tfl_wait_seconds_bucket{le="+Inf"} 0
+tfl_wait_seconds_sum 0
+tfl_wait_seconds_count 0
+`
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, b.String())
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("expected %d bytes written, got %d", b.Len(), n)
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if rec.Body.String() != expected {
+		t.Errorf("expected the handler to serve the exposition, got\n%s", rec.Body)
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		use  func(r *Registry)
+	}{
+		{"registered twice", func(r *Registry) {
+			r.NewCounterVec("tfl_requests_total", "")
+			r.NewGaugeVec("tfl_requests_total", "")
+		}},
+		{"wrong number of labels", func(r *Registry) {
+			r.NewCounterVec("tfl_requests_total", "", "endpoint").WithLabelValues()
+		}},
+		{"decreasing counter", func(r *Registry) {
+			r.NewCounterVec("tfl_requests_total", "").WithLabelValues().Add(-1)
+		}},
+	}
+	for _, tc := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", tc.name)
+				}
+			}()
+			tc.use(NewRegistry())
+		}()
+	}
+}
@@ -1,9 +1,12 @@
 package tfl
 
-const LineRoutesAPI = "https://api.tfl.gov.uk/Line/Mode/%s/Route?serviceTypes=Regular"
-const LineStationsAPI = "https://api.tfl.gov.uk/Line/%s/StopPoints"
-const LineStatusAPI = "https://api.tfl.gov.uk/Line/Mode/%s/Status"
-const LineArrivalsAPI = "https://api.tfl.gov.uk/Line/%s/Arrivals/%s"
-const LineStationSequenceAPI = "https://api.tfl.gov.uk/Line/%s/Route/Sequence/all"
-const VehicleArrivalsAPI = "https://api.tfl.gov.uk/Vehicle/%s/Arrivals"
-const TimetablesAPI = "https://api.tfl.gov.uk/Line/%s/Timetable/%s/to/%s"
+const DefaultBaseURL = "https://api.tfl.gov.uk"
+
+const LineRoutesAPI = "/Line/Mode/%s/Route?serviceTypes=Regular"
+const LineStationsAPI = "/Line/%s/StopPoints"
+const LineStatusAPI = "/Line/Mode/%s/Status"
+const LineArrivalsAPI = "/Line/%s/Arrivals/%s"
+const LineAllArrivalsAPI = "/Line/%s/Arrivals"
+const LineStationSequenceAPI = "/Line/%s/Route/Sequence/all"
+const VehicleArrivalsAPI = "/Vehicle/%s/Arrivals"
+const TimetablesAPI = "/Line/%s/Timetable/%s/to/%s"
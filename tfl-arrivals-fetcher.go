@@ -1,10 +1,10 @@
 package tfl
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"log/slog"
 	"sort"
 	"time"
 )
@@ -12,7 +12,10 @@ import (
 type tflStationArrival struct {
 	NaptanId        string
 	StationName     string
+	LineId          string
+	LineName        string
 	PlatformName    string
+	DestinationName string
 	Towards         string
 	CurrentLocation string
 	VehicleId       string
@@ -20,14 +23,34 @@ type tflStationArrival struct {
 	ExpectedArrival string
 }
 
+// TfL serves the same prediction from the station and the vehicle endpoints
+func (tsa tflStationArrival) vehicleArrival() tflVehicleArrivals {
+	return tflVehicleArrivals{
+		VehicleId:       tsa.VehicleId,
+		LineId:          tsa.LineId,
+		LineName:        tsa.LineName,
+		DestinationName: tsa.DestinationName,
+		Towards:         tsa.Towards,
+		NaptanId:        tsa.NaptanId,
+		StationName:     tsa.StationName,
+		TimeToStation:   tsa.TimeToStation,
+		CurrentLocation: tsa.CurrentLocation,
+		ExpectedArrival: tsa.ExpectedArrival,
+	}
+}
+
 func (tsa tflStationArrival) expectedArrivalAsTime() time.Time {
 	expectedArrival, err := time.Parse(time.RFC3339, tsa.ExpectedArrival)
 	if err != nil {
-		log.Printf("unable to parse %s as date: %v", tsa.ExpectedArrival, err)
+		slog.Warn("unable to parse expected arrival", "expected_arrival", tsa.ExpectedArrival, "station", tsa.NaptanId, "vehicle", tsa.VehicleId, "error", err)
 	}
 	return expectedArrival
 }
 
+func (tsa tflStationArrival) canBeTracked() bool {
+	return tsa.VehicleId != "" && Arrival{VehicleID: tsa.VehicleId}.CanBeTracked()
+}
+
 func (tsa tflStationArrival) calculateCurrentLocation() string {
 	if tsa.CurrentLocation != "" {
 		return tsa.CurrentLocation
@@ -42,23 +65,14 @@ func (tsa tflStationArrival) cleansedPlatformName() string {
 	return tsa.PlatformName
 }
 
-func (sf *remoteTFLHTTPFetcher) fetchArrivals(lineID, stationID string) (Arrivals, error) {
-	url := sf.arrivalsURL(lineID, stationID)
-	resp, err := sf.c.Get(url)
-	if err != nil {
-		return Arrivals{}, fmt.Errorf("problem fetching data from API: %v", err)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+func (sf *remoteTFLHTTPFetcher) fetchArrivals(ctx context.Context, lineID, stationID string) (Arrivals, error) {
+	body, err := sf.get(ctx, "arrivals", sf.arrivalsURL(lineID, stationID))
 	if err != nil {
-		return Arrivals{}, fmt.Errorf("problem reading data from response: %v", err)
-	}
-	if resp.StatusCode == 400 {
-		return Arrivals{}, nil
+		return Arrivals{}, fmt.Errorf("problem fetching data from API: %w", err)
 	}
 	tflStationArrivals := []tflStationArrival{}
 	if err := json.Unmarshal(body, &tflStationArrivals); err != nil {
-		return Arrivals{}, fmt.Errorf("problem parsing response data from TFL: %v", err)
+		return Arrivals{}, fmt.Errorf("problem parsing response data from TFL: %w", newDecodeError("arrivals", err))
 	}
 	if len(tflStationArrivals) == 0 {
 		return Arrivals{}, nil
@@ -70,6 +84,46 @@ func (sf *remoteTFLHTTPFetcher) fetchArrivals(lineID, stationID string) (Arrival
 	}, nil
 }
 
+func (sf *remoteTFLHTTPFetcher) fetchLineArrivals(ctx context.Context, lineID string) (LineArrivals, error) {
+	body, err := sf.get(ctx, "line_arrivals", sf.lineArrivalsURL(lineID))
+	if err != nil {
+		return LineArrivals{}, fmt.Errorf("problem fetching data from API: %w", err)
+	}
+	tflStationArrivals := []tflStationArrival{}
+	if err := json.Unmarshal(body, &tflStationArrivals); err != nil {
+		return LineArrivals{}, fmt.Errorf("problem parsing response data from TFL: %w", newDecodeError("line_arrivals", err))
+	}
+	return LineArrivals{
+		LineID:   lineID,
+		Vehicles: calculateVehicleSchedules(tflStationArrivals),
+	}, nil
+}
+
+// calculateVehicleSchedules groups predictions by vehicle; untrackable vehicles are dropped
+func calculateVehicleSchedules(tflStationArrivals []tflStationArrival) []VehicleSchedule {
+	byVehicle := make(map[string][]tflVehicleArrivals)
+	for _, arr := range tflStationArrivals {
+		if !arr.canBeTracked() {
+			continue
+		}
+		byVehicle[arr.VehicleId] = append(byVehicle[arr.VehicleId], arr.vehicleArrival())
+	}
+	result := make([]VehicleSchedule, 0, len(byVehicle))
+	for vehicleID, tva := range byVehicle {
+		result = append(result, VehicleSchedule{
+			VehicleID:       vehicleID,
+			Line:            tva[0].LineName,
+			Destination:     calculateVehicleDestination(tva),
+			CurrentLocation: calculateVehicleCurrentLocation(tva),
+			Stops:           calculateVehicleStops(tva),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].VehicleID < result[j].VehicleID
+	})
+	return result
+}
+
 func calculateArrivalsByPlatform(tflStationArrivals []tflStationArrival) []Platform {
 	buffer := make(map[string]*Platform)
 	for _, arr := range tflStationArrivals {
@@ -1,6 +1,7 @@
 package tfl
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -9,6 +10,7 @@ type Arrivals struct {
 	StationID   string
 	StationName string
 	Platforms   []Platform
+	Staleness
 }
 
 type Platform struct {
@@ -24,6 +26,13 @@ type Arrival struct {
 	ExpectedArrival time.Time
 }
 
+// LineArrivals holds the predicted stops of every trackable vehicle on a line.
+type LineArrivals struct {
+	LineID   string
+	Vehicles []VehicleSchedule
+	Staleness
+}
+
 func (a Arrival) CanBeTracked() bool {
 	return a.VehicleID != "000"
 }
@@ -33,5 +42,21 @@ func (a Arrival) ETA() string {
 }
 
 func (sd *tflAPIImpl) ArrivalsFor(lineID, stationID string) (Arrivals, error) {
-	return sd.fetcher.fetchArrivals(lineID, stationID)
+	return sd.ArrivalsForContext(context.Background(), lineID, stationID)
+}
+
+func (sd *tflAPIImpl) ArrivalsForContext(ctx context.Context, lineID, stationID string) (Arrivals, error) {
+	avls, staleAsOf, err := sd.arrivals.get(ctx, liveKey{lineID: lineID, id: stationID})
+	avls.StaleAsOf = staleAsOf
+	return avls, err
+}
+
+func (sd *tflAPIImpl) LineArrivals(lineID string) (LineArrivals, error) {
+	return sd.LineArrivalsContext(context.Background(), lineID)
+}
+
+func (sd *tflAPIImpl) LineArrivalsContext(ctx context.Context, lineID string) (LineArrivals, error) {
+	la, staleAsOf, err := sd.lineArrivals.get(ctx, liveKey{lineID: lineID})
+	la.StaleAsOf = staleAsOf
+	return la, err
 }
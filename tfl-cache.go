@@ -0,0 +1,64 @@
+package tfl
+
+import (
+	"context"
+	"time"
+)
+
+// backgroundFetchTimeout bounds a fetch made on behalf of a cache rather than a single caller
+const backgroundFetchTimeout = time.Second * 30
+
+type cacheTTLs struct {
+	lines    time.Duration
+	stations time.Duration
+	routes   time.Duration
+}
+
+// cachedValue is an entry in one of the static data caches owned by the monitor goroutines.
+// Entries are never evicted; once stale they are served while a refresh runs in the background.
+type cachedValue[V any] struct {
+	value      V
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+func newCachedValue[V any](v V) *cachedValue[V] {
+	return &cachedValue[V]{value: v, fetchedAt: time.Now()}
+}
+
+// needsRefresh reports whether the entry is stale and no refresh is already running
+func (c *cachedValue[V]) needsRefresh(ttl time.Duration) bool {
+	return !c.refreshing && time.Since(c.fetchedAt) > ttl
+}
+
+type fetchResult[K comparable, V any] struct {
+	key   K
+	value V
+	err   error
+}
+
+// backgroundFetch fetches key and reports back to the owning monitor goroutine on results, unless done is closed first.
+// Monitors never call TfL themselves so they stay responsive while TfL is slow.
+func backgroundFetch[K comparable, V any](key K, fetch func(context.Context, K) (V, error), results chan<- fetchResult[K, V], done <-chan struct{}) {
+	ctx, cancel := fetchContext(done)
+	defer cancel()
+	v, err := fetch(ctx, key)
+	select {
+	case results <- fetchResult[K, V]{key: key, value: v, err: err}:
+	case <-done:
+	}
+}
+
+// fetchContext bounds a background fetch and cancels it once done is closed,
+// so Close doesn't leave requests to TfL running
+func fetchContext(done <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), backgroundFetchTimeout)
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,296 @@
+package tfl
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// England & Wales bank holidays, in the format of https://www.gov.uk/bank-holidays.json.
+// Replace the file with a fresh download to update it, or point WithBankHolidays at one.
+//
+//go:embed data/bank-holidays.json
+var embeddedBankHolidays []byte
+
+const bankHolidayDivision = "england-and-wales"
+
+type govUKBankHolidays map[string]struct {
+	Events []struct {
+		Title string
+		Date  string
+	}
+}
+
+// serviceCalendar resolves a date to the TfL schedule that runs on it
+type serviceCalendar struct {
+	// bank holiday titles by London date, "2006-01-02"
+	holidays map[string]string
+}
+
+func parseBankHolidays(data []byte) (*serviceCalendar, error) {
+	var input govUKBankHolidays
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, fmt.Errorf("problem parsing bank holidays: %w", err)
+	}
+	division, ok := input[bankHolidayDivision]
+	if !ok {
+		return nil, fmt.Errorf("no %s bank holidays found", bankHolidayDivision)
+	}
+	result := &serviceCalendar{holidays: make(map[string]string, len(division.Events))}
+	for _, e := range division.Events {
+		if _, err := time.Parse("2006-01-02", e.Date); err != nil {
+			return nil, fmt.Errorf("invalid bank holiday date %q: %w", e.Date, err)
+		}
+		result.holidays[e.Date] = e.Title
+	}
+	return result, nil
+}
+
+func loadBankHolidays(path string) (*serviceCalendar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseBankHolidays(data)
+}
+
+func mustParseEmbeddedBankHolidays() *serviceCalendar {
+	cal, err := parseBankHolidays(embeddedBankHolidays)
+	if err != nil {
+		panic(err)
+	}
+	return cal
+}
+
+// bankHoliday returns the title of the bank holiday on the London date of date, if any
+func (c *serviceCalendar) bankHoliday(date time.Time) (string, bool) {
+	title, ok := c.holidays[gmtc.convert(date).Format("2006-01-02")]
+	return title, ok
+}
+
+// upcomingBankHolidays lists the bank holidays on or after the London date of from, in order
+func (c *serviceCalendar) upcomingBankHolidays(from time.Time) []time.Time {
+	today := gmtc.convert(from).Format("2006-01-02")
+	var result []time.Time
+	for d := range c.holidays {
+		if d < today {
+			continue
+		}
+		t, _ := time.ParseInLocation("2006-01-02", d, gmtc.loc)
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result
+}
+
+// scheduleFor picks the schedule running on date. On a bank holiday that's the schedule naming it,
+// then one naming bank holidays in general, and otherwise the Sunday schedule.
+// Schedules running "except" on the holiday are never picked for it. ok is false when no schedule runs on date.
+func (c *serviceCalendar) scheduleFor(date time.Time, schedules []timeTableDetails) (timeTableDetails, bool) {
+	if title, ok := c.bankHoliday(date); ok {
+		words := holidayWords(title)
+		if s, ok := scheduleForHoliday(words, schedules); ok {
+			return s, true
+		}
+		running := make([]timeTableDetails, 0, len(schedules))
+		for _, s := range schedules {
+			if !s.days.exceptsHoliday(words) {
+				running = append(running, s)
+			}
+		}
+		return scheduleForWeekday(time.Sunday, running)
+	}
+	return scheduleForWeekday(gmtc.convert(date).Weekday(), schedules)
+}
+
+func scheduleForHoliday(words []string, schedules []timeTableDetails) (timeTableDetails, bool) {
+	for _, s := range schedules {
+		if s.days.namesHoliday(words) {
+			return s, true
+		}
+	}
+	for _, s := range schedules {
+		if s.days.allHolidays {
+			return s, true
+		}
+	}
+	return timeTableDetails{}, false
+}
+
+// scheduleForWeekday prefers the schedule running on the fewest days, eg. Friday over Monday - Friday.
+// ok is false when none runs on weekday.
+func scheduleForWeekday(weekday time.Weekday, schedules []timeTableDetails) (timeTableDetails, bool) {
+	best := -1
+	for i, s := range schedules {
+		if s.days.weekdays[weekday] && (best < 0 || s.days.count() < schedules[best].days.count()) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return timeTableDetails{}, false
+	}
+	return schedules[best], true
+}
+
+// fallbackSchedule stands in when no schedule runs on a day. TfL doesn't always publish every day
+// of a single route timetable, so its Monday schedule is used; failing that, the last one.
+func fallbackSchedule(schedules []timeTableDetails) timeTableDetails {
+	if s, ok := scheduleForWeekday(time.Monday, schedules); ok {
+		return s
+	}
+	if len(schedules) == 0 {
+		return timeTableDetails{}
+	}
+	return schedules[len(schedules)-1]
+}
+
+// serviceDays are the days a TfL schedule runs on, read from its name,
+// eg. "Monday - Thursday", "Saturday (also Good Friday)" or "Monday - Friday (except Bank Holidays)"
+type serviceDays struct {
+	weekdays [7]bool
+	// words of the name, and of what follows "except", for matching bank holiday titles
+	words, exceptWords []string
+	allHolidays        bool
+	exceptAllHolidays  bool
+}
+
+func (sd serviceDays) count() int {
+	n := 0
+	for _, ok := range sd.weekdays {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// namesHoliday reports whether the schedule names the bank holiday with the significant words holiday
+func (sd serviceDays) namesHoliday(holiday []string) bool {
+	return containsHolidayWords(sd.words, holiday)
+}
+
+func (sd serviceDays) exceptsHoliday(holiday []string) bool {
+	return sd.exceptAllHolidays || containsHolidayWords(sd.exceptWords, holiday)
+}
+
+// containsHolidayWords reports whether every one of holiday is in words, which may abbreviate them, eg. "Good Fri"
+func containsHolidayWords(words, holiday []string) bool {
+	if len(holiday) == 0 {
+		return false
+	}
+	for _, hw := range holiday {
+		found := false
+		for _, w := range words {
+			if w == hw || len(w) >= 3 && strings.HasPrefix(hw, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (sd serviceDays) weekdayList() []time.Weekday {
+	var result []time.Weekday
+	for _, d := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		if sd.weekdays[d] {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+	"sunday": time.Sunday, "sun": time.Sunday,
+}
+
+var (
+	scheduleWord = regexp.MustCompile(`[a-z]+`)
+	// bank holidays whose title contains "bank holiday" aren't bank holidays in general
+	namedBankHolidays = regexp.MustCompile(`(early may|spring|summer) bank holiday`)
+	anyHoliday        = regexp.MustCompile(`\b(bank|public) holidays?\b`)
+	except            = regexp.MustCompile(`\b(except|excluding|not)\b`)
+)
+
+// holidayTitleFillers don't tell one bank holiday from another
+var holidayTitleFillers = map[string]bool{
+	"day": true, "bank": true, "holiday": true, "holidays": true, "the": true, "and": true, "of": true, "for": true,
+}
+
+// holidayWords are the words of a bank holiday title that identify it, eg. "good" and "friday"
+func holidayWords(title string) []string {
+	var result []string
+	for _, w := range scheduleWord.FindAllString(strings.ToLower(title), -1) {
+		// the s of "New Year's Day"
+		if len(w) > 1 && !holidayTitleFillers[w] {
+			result = append(result, w)
+		}
+	}
+	return result
+}
+
+func parseServiceDays(scheduleName string) serviceDays {
+	name := strings.ToLower(scheduleName)
+	var exceptions string
+	if loc := except.FindStringIndex(name); loc != nil {
+		name, exceptions = name[:loc[0]], name[loc[1]:]
+	}
+	result := serviceDays{
+		words:             scheduleWord.FindAllString(name, -1),
+		exceptWords:       scheduleWord.FindAllString(exceptions, -1),
+		allHolidays:       anyHoliday.MatchString(namedBankHolidays.ReplaceAllString(name, "")),
+		exceptAllHolidays: anyHoliday.MatchString(namedBankHolidays.ReplaceAllString(exceptions, "")),
+	}
+	// weekdays come before any "(also ...)"
+	main := name
+	if i := strings.Index(main, "("); i >= 0 {
+		main = main[:i]
+	}
+	if strings.Contains(main, "daily") || strings.Contains(main, "every day") {
+		for d := range result.weekdays {
+			result.weekdays[d] = true
+		}
+		return result
+	}
+	if strings.Contains(main, "weekday") {
+		for d := time.Monday; d <= time.Friday; d++ {
+			result.weekdays[d] = true
+		}
+	}
+	prevEnd, prevDay := -1, time.Weekday(-1)
+	for _, loc := range scheduleWord.FindAllStringIndex(main, -1) {
+		word := strings.TrimSuffix(main[loc[0]:loc[1]], "s")
+		day, ok := weekdayNames[word]
+		if !ok {
+			day, ok = weekdayNames[main[loc[0]:loc[1]]]
+		}
+		if !ok {
+			continue
+		}
+		result.weekdays[day] = true
+		if prevDay >= 0 {
+			between := main[prevEnd:loc[0]]
+			if strings.Contains(between, "-") || strings.Contains(between, "–") || strings.Contains(between, " to ") {
+				for d := prevDay; d != day; d = (d + 1) % 7 {
+					result.weekdays[d] = true
+				}
+			}
+		}
+		prevEnd, prevDay = loc[1], day
+	}
+	return result
+}
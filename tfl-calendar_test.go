@@ -0,0 +1,94 @@
+package tfl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseServiceDays(t *testing.T) {
+	tests := []struct {
+		name        string
+		weekdays    []time.Weekday
+		allHolidays bool
+	}{
+		{"Monday - Friday", []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, false},
+		{"Monday - Thursday", []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday}, false},
+		{"Friday", []time.Weekday{time.Friday}, false},
+		{"Saturday & Sunday", []time.Weekday{time.Saturday, time.Sunday}, false},
+		{"Fri - Mon", []time.Weekday{time.Monday, time.Friday, time.Saturday, time.Sunday}, false},
+		{"Daily", []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}, false},
+		{"Weekdays", []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, false},
+		{"Sunday (also Christmas)", []time.Weekday{time.Sunday}, false},
+		{"Sunday and Bank Holidays", []time.Weekday{time.Sunday}, true},
+		{"Monday - Friday (except Bank Holidays)", []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}, false},
+		{"Monday - Saturday except Sunday", []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}, false},
+		{"Spring Bank Holiday", nil, false},
+		{"Bank Holidays", nil, true},
+	}
+	for _, tc := range tests {
+		days := parseServiceDays(tc.name)
+		if got := days.weekdayList(); !reflect.DeepEqual(got, tc.weekdays) {
+			t.Errorf("%q: expected weekdays %v, got %v", tc.name, tc.weekdays, got)
+		}
+		if days.allHolidays != tc.allHolidays {
+			t.Errorf("%q: expected allHolidays %v, got %v", tc.name, tc.allHolidays, days.allHolidays)
+		}
+	}
+}
+
+func TestScheduleFor(t *testing.T) {
+	cal, err := parseBankHolidays([]byte(`{"england-and-wales": {"events": [
+		{"title": "New Year’s Day", "date": "2026-01-01"},
+		{"title": "Good Friday", "date": "2026-04-03"},
+		{"title": "Easter Monday", "date": "2026-04-06"},
+		{"title": "Early May bank holiday", "date": "2026-05-04"},
+		{"title": "Spring bank holiday", "date": "2026-05-25"},
+		{"title": "Christmas Day", "date": "2026-12-25"},
+		{"title": "Boxing Day", "date": "2026-12-28"}
+	]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedulesNamed := func(names ...string) []timeTableDetails {
+		var result []timeTableDetails
+		for _, name := range names {
+			result = append(result, timeTableDetails{scheduleName: name, days: parseServiceDays(name)})
+		}
+		return result
+	}
+	london := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 12, 0, 0, 0, gmtc.loc)
+	}
+
+	tests := []struct {
+		schedules []timeTableDetails
+		date      time.Time
+		expected  string
+	}{
+		// Thursday 8 October 2026 and Friday 9 October 2026
+		{schedulesNamed("Monday - Thursday", "Friday", "Saturday", "Sunday"), london(time.October, 8), "Monday - Thursday"},
+		{schedulesNamed("Monday - Thursday", "Friday", "Saturday", "Sunday"), london(time.October, 9), "Friday"},
+		{schedulesNamed("Monday - Friday", "Saturday", "Sunday (also Christmas)"), london(time.December, 25), "Sunday (also Christmas)"},
+		{schedulesNamed("Monday - Friday", "Saturday (and Good Fri)", "Sunday"), london(time.April, 3), "Saturday (and Good Fri)"},
+		{schedulesNamed("Monday - Friday", "Saturday", "Sunday (also New Year's Day)"), london(time.January, 1), "Sunday (also New Year's Day)"},
+		// naming one holiday doesn't name another
+		{schedulesNamed("Monday - Friday", "Saturday (and Good Fri)", "Sunday"), london(time.April, 6), "Sunday"},
+		{schedulesNamed("Monday - Friday", "Saturday", "Sunday (also Christmas)"), london(time.December, 28), "Sunday (also Christmas)"},
+		{schedulesNamed("Monday - Friday", "Saturday", "Sunday", "Spring Bank Holiday"), london(time.May, 25), "Spring Bank Holiday"},
+		{schedulesNamed("Monday - Friday", "Saturday", "Sunday", "Spring Bank Holiday"), london(time.May, 4), "Sunday"},
+		{schedulesNamed("Monday - Friday", "Saturday", "Sunday and Bank Holidays"), london(time.May, 4), "Sunday and Bank Holidays"},
+		// a schedule excepting bank holidays isn't a bank holiday schedule
+		{schedulesNamed("Monday - Friday (except Bank Holidays)", "Sunday"), london(time.April, 6), "Sunday"},
+		{schedulesNamed("Monday - Friday (except Bank Holidays)", "Saturday", "Sunday"), london(time.April, 7), "Monday - Friday (except Bank Holidays)"},
+		{schedulesNamed("Daily (except Christmas Day)", "Christmas Day"), london(time.December, 25), "Christmas Day"},
+		{schedulesNamed("Daily", "Sunday (except Christmas Day)"), london(time.December, 25), "Daily"},
+		{schedulesNamed("Daily", "Sunday (except Christmas Day)"), london(time.December, 28), "Sunday (except Christmas Day)"},
+	}
+	for _, tc := range tests {
+		got, _ := cal.scheduleFor(tc.date, tc.schedules)
+		if got.scheduleName != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.date.Format("Mon 2 Jan"), tc.expected, got.scheduleName)
+		}
+	}
+}
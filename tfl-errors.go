@@ -0,0 +1,114 @@
+package tfl
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Sentinel errors returned (wrapped) by every TFLAPI call that talks to TfL.
+// Branch on them with errors.Is; use errors.As with *APIError for details such as RetryAfter.
+var (
+	ErrNotFound            = errors.New("not found")
+	ErrBadRequest          = errors.New("bad request")
+	ErrRateLimited         = errors.New("rate limited")
+	ErrUpstreamUnavailable = errors.New("upstream unavailable")
+	ErrDecode              = errors.New("unable to decode response")
+)
+
+// ErrClosed is returned by calls made after Close.
+var ErrClosed = errors.New("tfl: closed")
+
+// APIError describes a failed call to a TfL endpoint.
+type APIError struct {
+	// Kind is one of the sentinel errors above
+	Kind       error
+	Endpoint   string
+	StatusCode int
+	// RetryAfter is set from the Retry-After header when TfL rate limits us
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s: %v", e.Endpoint, e.Kind)
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s (HTTP %d)", msg, e.StatusCode)
+	}
+	if e.RetryAfter > 0 {
+		msg = fmt.Sprintf("%s, retry after %s", msg, e.RetryAfter)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *APIError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+func newStatusError(endpoint string, statusCode int, retryAfter string) error {
+	if statusCode >= 200 && statusCode < 300 {
+		return nil
+	}
+	apiErr := &APIError{
+		Endpoint:   endpoint,
+		StatusCode: statusCode,
+	}
+	switch {
+	case statusCode == http.StatusNotFound:
+		apiErr.Kind = ErrNotFound
+	case statusCode == http.StatusTooManyRequests:
+		apiErr.Kind = ErrRateLimited
+		apiErr.RetryAfter = parseRetryAfter(retryAfter, time.Now())
+	case statusCode >= 500:
+		apiErr.Kind = ErrUpstreamUnavailable
+	default:
+		apiErr.Kind = ErrBadRequest
+	}
+	return apiErr
+}
+
+func newUnavailableError(endpoint string, err error) error {
+	return &APIError{
+		Kind:     ErrUpstreamUnavailable,
+		Endpoint: endpoint,
+		Err:      err,
+	}
+}
+
+func newDecodeError(endpoint string, err error) error {
+	return &APIError{
+		Kind:     ErrDecode,
+		Endpoint: endpoint,
+		Err:      err,
+	}
+}
+
+// parseRetryAfter understands both forms allowed by RFC 9110: delay-seconds and HTTP-date
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0
+	}
+	if d := t.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,418 @@
+package tfl_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/arunsworld/tfl/tfltest"
+)
+
+// newTestAPI talks to srv without retries or live data caching, so every call reaches the fake
+func newTestAPI(t *testing.T, srv *tfltest.Server, opts ...tfl.Option) tfl.TFLAPI {
+	t.Helper()
+	opts = append([]tfl.Option{
+		tfl.WithBaseURL(srv.URL),
+		tfl.WithRetry(1, 0, 0),
+		tfl.WithLiveDataTTL(0),
+		tfl.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	}, opts...)
+	api := tfl.New(opts...)
+	t.Cleanup(func() { api.Close() })
+	return api
+}
+
+func newTestServer(t *testing.T) *tfltest.Server {
+	t.Helper()
+	srv := tfltest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func expectedArrival(in time.Duration) string {
+	return time.Now().Add(in).UTC().Format(time.RFC3339)
+}
+
+func TestLines(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetLines("tube", tfltest.Line{ID: "victoria", Name: "Victoria"}, tfltest.Line{ID: "bakerloo", Name: "Bakerloo"})
+	srv.SetStatus("tube", tfltest.LineStatus{
+		ID: "victoria",
+		LineStatuses: []tfltest.LineStatusDetail{
+			{StatusSeverityDescription: "Minor Delays", Reason: "Signal failure at Brixton"},
+			{StatusSeverityDescription: "Minor Delays", Reason: "Signal failure at Brixton"},
+		},
+	})
+	api := newTestAPI(t, srv)
+
+	lines := api.Lines("tube", true)
+	if len(lines) != 2 || lines[0].ID != "bakerloo" || lines[1].ID != "victoria" {
+		t.Fatalf("expected bakerloo and victoria sorted by ID, got %+v", lines)
+	}
+	if got := lines[1].Status.StatusDescriptions; !reflect.DeepEqual(got, []string{"Signal failure at Brixton"}) {
+		t.Errorf("expected the reason once as the victoria status, got %q", got)
+	}
+	if got := lines[0].Status.StatusDescriptions; len(got) != 0 {
+		t.Errorf("expected no bakerloo status, got %q", got)
+	}
+}
+
+func TestStationsAndRoutes(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetStopPoints("victoria",
+		tfltest.StopPoint{ID: "940GZZLUVIC", CommonName: "Victoria Underground Station", Lat: 51.4965, Lon: -0.1447},
+		tfltest.StopPoint{ID: "940GZZLUBXN", CommonName: "Brixton Underground Station"},
+		tfltest.StopPoint{ID: "940GZZLUOXC", CommonName: "Oxford Circus Underground Station"},
+	)
+	srv.SetRouteSequence("victoria", tfltest.OrderedLineRoute{
+		Name:      "Brixton - Walthamstow Central",
+		NaptanIDs: []string{"940GZZLUBXN", "940GZZLUVIC", "940GZZLUOXC", "940GZZLUWWL"},
+	})
+	api := newTestAPI(t, srv)
+
+	stations := api.Stations("victoria")
+	names := make([]string, 0, len(stations))
+	for _, s := range stations {
+		names = append(names, s.ShortName())
+	}
+	if !reflect.DeepEqual(names, []string{"Brixton", "Oxford Circus", "Victoria"}) {
+		t.Fatalf("expected stations sorted by name, got %q", names)
+	}
+	if stations[2].Lat != 51.4965 || stations[2].Lon != -0.1447 {
+		t.Errorf("expected Victoria's coordinates, got %v,%v", stations[2].Lat, stations[2].Lon)
+	}
+
+	routes := api.Routes("victoria")
+	if len(routes) != 1 {
+		t.Fatalf("expected one route, got %+v", routes)
+	}
+	// Walthamstow isn't one of the line's stop points so it is dropped
+	if routes[0].Start() != "940GZZLUBXN" || routes[0].Dest() != "940GZZLUOXC" || len(routes[0].Stations) != 3 {
+		t.Errorf("expected Brixton to Oxford Circus in sequence, got %+v", routes[0].Stations)
+	}
+}
+
+func TestArrivalsFor(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetArrivals("victoria", "940GZZLUOXC",
+		tfltest.Arrival{VehicleID: "202", NaptanID: "940GZZLUOXC", StationName: "Oxford Circus Underground Station", LineID: "victoria", PlatformName: "Northbound - Platform 5", Towards: "Walthamstow Central", TimeToStation: 240, ExpectedArrival: expectedArrival(4 * time.Minute)},
+		tfltest.Arrival{VehicleID: "201", NaptanID: "940GZZLUOXC", StationName: "Oxford Circus Underground Station", LineID: "victoria", PlatformName: "Northbound - Platform 5", Towards: "Walthamstow Central", TimeToStation: 60, ExpectedArrival: expectedArrival(time.Minute)},
+		tfltest.Arrival{VehicleID: "000", NaptanID: "940GZZLUOXC", StationName: "Oxford Circus Underground Station", LineID: "victoria", PlatformName: "null", Towards: "Brixton", TimeToStation: 120, ExpectedArrival: expectedArrival(2 * time.Minute)},
+	)
+	api := newTestAPI(t, srv)
+
+	arrivals, err := api.ArrivalsFor("victoria", "940GZZLUOXC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arrivals.StationID != "940GZZLUOXC" || arrivals.IsStale() {
+		t.Errorf("expected fresh arrivals at Oxford Circus, got %+v", arrivals)
+	}
+	if len(arrivals.Platforms) != 2 {
+		t.Fatalf("expected two platforms, got %+v", arrivals.Platforms)
+	}
+	northbound := arrivals.Platforms[0]
+	if northbound.Name != "Northbound - Platform 5" || len(northbound.Arrivals) != 2 || northbound.Arrivals[0].VehicleID != "201" {
+		t.Errorf("expected vehicle 201 first on the northbound platform, got %+v", northbound)
+	}
+	if northbound.Arrivals[0].CurrentLocation != "Not Available" {
+		t.Errorf("expected a placeholder current location, got %q", northbound.Arrivals[0].CurrentLocation)
+	}
+	if arrivals.Platforms[1].Name != "Platform Not Specified" {
+		t.Errorf("expected a placeholder platform name, got %q", arrivals.Platforms[1].Name)
+	}
+}
+
+func TestLineArrivalsAndVehicleSchedule(t *testing.T) {
+	srv := newTestServer(t)
+	predictions := []tfltest.Arrival{
+		{VehicleID: "201", NaptanID: "940GZZLUGPK", StationName: "Green Park Underground Station", LineID: "victoria", LineName: "Victoria", DestinationName: "Walthamstow Central Underground Station", CurrentLocation: "Approaching Green Park", TimeToStation: 30, ExpectedArrival: expectedArrival(30 * time.Second)},
+		{VehicleID: "201", NaptanID: "940GZZLUOXC", StationName: "Oxford Circus Underground Station", LineID: "victoria", LineName: "Victoria", DestinationName: "Walthamstow Central Underground Station", TimeToStation: 150, ExpectedArrival: expectedArrival(150 * time.Second)},
+		{VehicleID: "000", NaptanID: "940GZZLUOXC", StationName: "Oxford Circus Underground Station", LineID: "victoria", LineName: "Victoria", TimeToStation: 200, ExpectedArrival: expectedArrival(200 * time.Second)},
+	}
+	srv.SetLineArrivals("victoria", predictions...)
+	// TfL returns a vehicle's predictions on every line it shares a number with
+	srv.SetVehicleArrivals("201", append(predictions[:2:2], tfltest.Arrival{VehicleID: "201", NaptanID: "940GZZLUBST", LineID: "jubilee", LineName: "Jubilee", TimeToStation: 10, ExpectedArrival: expectedArrival(10 * time.Second)})...)
+	api := newTestAPI(t, srv)
+
+	la, err := api.LineArrivals("victoria")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if la.LineID != "victoria" || len(la.Vehicles) != 1 || la.Vehicles[0].VehicleID != "201" {
+		t.Fatalf("expected only trackable vehicle 201, got %+v", la)
+	}
+
+	vs, err := api.VehicleScheduleFor("victoria", "201")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vs.Line != "Victoria" || vs.Destination != "Walthamstow Central Underground Station" || vs.CurrentLocation != "Approaching Green Park" {
+		t.Errorf("unexpected vehicle schedule %+v", vs)
+	}
+	if len(vs.Stops) != 2 {
+		t.Errorf("expected the two victoria stops only, got %+v", vs.Stops)
+	}
+}
+
+func TestTimetable(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetTimetable("victoria", "940GZZLUBXN", "940GZZLUWWL", tfltest.Timetable{
+		Stops: []tfltest.TimetableStop{
+			{ID: "940GZZLUBXN", Name: "Brixton Underground Station"},
+			{ID: "940GZZLUVIC", Name: "Victoria Underground Station"},
+			{ID: "940GZZLUWWL", Name: "Walthamstow Central Underground Station"},
+		},
+		Timetable: tfltest.TimetableRouteList{Routes: []tfltest.TimetableRoute{{
+			StationIntervals: []tfltest.StationInterval{{
+				ID: "0",
+				Intervals: []tfltest.Interval{
+					{StopID: "940GZZLUVIC", TimeToArrival: 6},
+					{StopID: "940GZZLUWWL", TimeToArrival: 32},
+				},
+			}},
+			Schedules: []tfltest.Schedule{
+				{Name: "Monday - Friday", KnownJourneys: []tfltest.KnownJourney{{Hour: "6", Minute: "5"}, {Hour: "5", Minute: "35"}}},
+				{Name: "Saturday", KnownJourneys: []tfltest.KnownJourney{{Hour: "6", Minute: "35"}}},
+				{Name: "Sunday", KnownJourneys: []tfltest.KnownJourney{{Hour: "24", Minute: "15"}}},
+			},
+		}}},
+	})
+	api := newTestAPI(t, srv)
+
+	tt, err := api.Timetable("victoria", "940GZZLUBXN", "940GZZLUWWL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tt.From.ShortName() != "Brixton" || tt.To.ShortName() != "Walthamstow Central" {
+		t.Errorf("unexpected stations %+v to %+v", tt.From, tt.To)
+	}
+	if len(tt.Schedules) != 3 || tt.Schedules[0].Name != "Monday - Friday" {
+		t.Fatalf("expected three schedules, got %+v", tt.Schedules)
+	}
+	weekday := tt.Schedules[0]
+	if len(weekday.Weekdays) != 5 || len(weekday.Journeys) != 2 {
+		t.Fatalf("expected two journeys from Monday to Friday, got %+v", weekday)
+	}
+	first := weekday.Journeys[0]
+	if first.DepartureTime.ETD() != "05:35" || first.DepartureTime.DestinationETA() != "06:07" || len(first.Stops) != 2 {
+		t.Errorf("expected the 05:35 arriving at 06:07 first, got %+v", first)
+	}
+	if got := tt.Schedules[2].Journeys[0].DepartureTime.Hour(); got != "24" {
+		t.Errorf("expected the Sunday journey to leave at hour 24, got %s", got)
+	}
+}
+
+func TestErrorsMapToSentinels(t *testing.T) {
+	srv := newTestServer(t)
+	api := newTestAPI(t, srv)
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, tfl.ErrNotFound},
+		{http.StatusBadRequest, tfl.ErrBadRequest},
+		{http.StatusInternalServerError, tfl.ErrUpstreamUnavailable},
+		{http.StatusServiceUnavailable, tfl.ErrUpstreamUnavailable},
+	}
+	for _, tc := range tests {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			vehicleID := "v" + http.StatusText(tc.status)
+			srv.SetError(tfltest.VehicleArrivalsPath(vehicleID), tc.status)
+			srv.SetError(tfltest.TimetablePath("victoria", vehicleID, "940GZZLUWWL"), tc.status)
+
+			_, err := api.VehicleScheduleFor("victoria", vehicleID)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("vehicles: expected %v, got %v", tc.want, err)
+			}
+			var apiErr *tfl.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.status || apiErr.Endpoint != "vehicles" {
+				t.Errorf("vehicles: expected an APIError for HTTP %d, got %#v", tc.status, apiErr)
+			}
+			if _, err := api.Timetable("victoria", vehicleID, "940GZZLUWWL"); !errors.Is(err, tc.want) {
+				t.Errorf("timetable: expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+
+	// paths with no fixture are unknown to TfL
+	if _, err := api.ArrivalsFor("victoria", "940GZZLUXXX"); !errors.Is(err, tfl.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unscripted path, got %v", err)
+	}
+}
+
+func TestRateLimitPausesAllRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []tfl.Option
+	}{
+		{"default rate limit", nil},
+		{"no rate limit", []tfl.Option{tfl.WithRateLimit(0, 0)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			srv.SetArrivals("victoria", "940GZZLUOXC")
+			srv.SetLineArrivals("victoria")
+			srv.RateLimitNext(tfltest.ArrivalsPath("victoria", "940GZZLUOXC"), 1, time.Second)
+			api := newTestAPI(t, srv, tc.opts...)
+
+			_, err := api.ArrivalsFor("victoria", "940GZZLUOXC")
+			var apiErr *tfl.APIError
+			if !errors.Is(err, tfl.ErrRateLimited) || !errors.As(err, &apiErr) || apiErr.RetryAfter != time.Second {
+				t.Fatalf("expected ErrRateLimited retrying after 1s, got %v", err)
+			}
+
+			// a different endpoint has to wait out the pause too
+			start := time.Now()
+			if _, err := api.LineArrivals("victoria"); err != nil {
+				t.Fatal(err)
+			}
+			if took := time.Since(start); took < 800*time.Millisecond {
+				t.Errorf("expected the next request to wait for the Retry-After, it took %s", took)
+			}
+			if _, err := api.ArrivalsFor("victoria", "940GZZLUOXC"); err != nil {
+				t.Errorf("expected the fixture once the limit passed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLatencyHonoursContext(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetArrivals("victoria", "940GZZLUOXC")
+	srv.SetLatency(tfltest.AnyPath, 2*time.Second)
+	api := newTestAPI(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := api.ArrivalsForContext(ctx, "victoria", "940GZZLUOXC")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("expected to give up with the context, it took %s", took)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := api.TimetableContext(ctx, "victoria", "940GZZLUBXN", "940GZZLUWWL"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the timetable to give up with the context, got %v", err)
+	}
+}
+
+func TestCloseStopsBackgroundGoroutines(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetLines("tube", tfltest.Line{ID: "victoria", Name: "Victoria"})
+	srv.SetArrivals("victoria", "940GZZLUOXC")
+	before := runtime.NumGoroutine()
+
+	api := tfl.New(
+		tfl.WithBaseURL(srv.URL),
+		tfl.WithHTTPClient(&http.Client{Transport: &http.Transport{DisableKeepAlives: true}}),
+		tfl.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		tfl.WithSnapshot(filepath.Join(t.TempDir(), "snapshot.json"), time.Hour),
+		// never warms up since there are no stations, so it keeps retrying until Close
+		tfl.WithWarmup("tube"),
+	)
+	if len(api.Lines("tube", false)) != 1 {
+		t.Fatal("expected the victoria line")
+	}
+	if _, err := api.ArrivalsFor("victoria", "940GZZLUOXC"); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Close(); err != nil {
+		t.Fatalf("expected a second Close to be a no-op, got %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d goroutines after Close, got %d", before, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := api.ArrivalsFor("victoria", "940GZZLUOXC"); !errors.Is(err, tfl.ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestCloseCancelsRequestsToTfL(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetArrivals("victoria", "940GZZLUOXC")
+	srv.SetLatency(tfltest.ArrivalsPath("victoria", "940GZZLUOXC"), time.Minute)
+	before := runtime.NumGoroutine()
+
+	api := tfl.New(
+		tfl.WithBaseURL(srv.URL),
+		tfl.WithHTTPClient(&http.Client{Transport: &http.Transport{DisableKeepAlives: true}}),
+		tfl.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+	// the caller gives up, but the fetch carries on for anyone coalesced onto it
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	if _, err := api.ArrivalsForContext(ctx, "victoria", "940GZZLUOXC"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the caller to time out, got %v", err)
+	}
+	if srv.RequestCount(tfltest.ArrivalsPath("victoria", "940GZZLUOXC")) != 1 {
+		t.Fatal("expected the request to reach TfL")
+	}
+	if err := api.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the request to TfL to be cancelled by Close, %d goroutines remain of %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLivenessWhileTfLHangs(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetLines("tube", tfltest.Line{ID: "victoria", Name: "Victoria"})
+	srv.SetStopPoints("victoria")
+	srv.SetRouteSequence("victoria")
+	srv.SetLatency(tfltest.AnyPath, time.Second)
+	api := newTestAPI(t, srv)
+
+	var wg sync.WaitGroup
+	for _, call := range []func(){
+		func() { api.Lines("tube", false) },
+		func() { api.Stations("victoria") },
+		func() { api.Routes("victoria") },
+		func() { api.Timetable("victoria", "940GZZLUBXN", "940GZZLUWWL") },
+	} {
+		wg.Add(1)
+		go func(call func()) {
+			defer wg.Done()
+			call()
+		}(call)
+	}
+	defer wg.Wait()
+	time.Sleep(50 * time.Millisecond)
+
+	// every monitor has a fetch in flight, which must not stop it answering
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	for _, check := range api.Liveness(ctx) {
+		if !check.OK {
+			t.Errorf("expected %s to be responsive, got %q", check.Name, check.Detail)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package tfl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// livenessTimeout bounds how long a monitor goroutine may take to answer a ping.
+// Monitors never wait on TfL, so one that doesn't answer in time is stuck.
+const livenessTimeout = time.Second * 10
+
+// warmupRetryInterval is how long to wait before retrying a warm-up that didn't fill the caches
+const warmupRetryInterval = time.Second * 30
+
+// Check is the outcome of one health or readiness check.
+type Check struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// monitorPing is answered by a monitor goroutine with the number of entries in its cache
+type monitorPing chan int
+
+// ping waits for a monitor to answer; ok is false if it doesn't answer in time
+func ping(ctx context.Context, pings chan monitorPing) (entries int, ok bool) {
+	ctx, cancel := context.WithTimeout(ctx, livenessTimeout)
+	defer cancel()
+	p := make(monitorPing, 1)
+	select {
+	case pings <- p:
+	case <-ctx.Done():
+		return 0, false
+	}
+	select {
+	case entries = <-p:
+		return entries, true
+	case <-ctx.Done():
+		return 0, false
+	}
+}
+
+type monitorPings struct {
+	name  string
+	pings chan monitorPing
+}
+
+func (sd *tflAPIImpl) monitors() []monitorPings {
+	return []monitorPings{
+		{name: "lines", pings: sd.linePings},
+		{name: "stations", pings: sd.stationPings},
+		{name: "routes", pings: sd.routePings},
+		{name: "timetables", pings: sd.timetablePings},
+	}
+}
+
+// Liveness checks that every monitor goroutine is responsive.
+func (sd *tflAPIImpl) Liveness(ctx context.Context) []Check {
+	monitors := sd.monitors()
+	checks := make([]Check, len(monitors))
+	var wg sync.WaitGroup
+	for i, m := range monitors {
+		wg.Add(1)
+		go func(i int, m monitorPings) {
+			defer wg.Done()
+			check := Check{Name: "monitor:" + m.name, OK: true, Detail: "responsive"}
+			if _, ok := ping(ctx, m.pings); !ok {
+				check.OK = false
+				check.Detail = "not responding"
+			}
+			checks[i] = check
+		}(i, m)
+	}
+	wg.Wait()
+	return checks
+}
+
+// Readiness checks that the static caches are warm and that TfL is answering.
+func (sd *tflAPIImpl) Readiness(ctx context.Context) []Check {
+	return []Check{sd.cacheCheck(ctx), sd.fetcher.health.check(sd.fetcher.breakers)}
+}
+
+func (sd *tflAPIImpl) cacheCheck(ctx context.Context) Check {
+	check := Check{Name: "caches"}
+	var sizes []string
+	for _, m := range sd.monitors() {
+		n, ok := ping(ctx, m.pings)
+		if !ok {
+			check.Detail = fmt.Sprintf("%s cache not responding", m.name)
+			return check
+		}
+		sizes = append(sizes, fmt.Sprintf("%s=%d", m.name, n))
+	}
+	switch {
+	case len(sd.warmupModes) == 0:
+		check.OK = true
+		check.Detail = "no warm-up configured; " + strings.Join(sizes, " ")
+	case sd.warm.Load():
+		check.OK = true
+		check.Detail = "warm; " + strings.Join(sizes, " ")
+	default:
+		check.Detail = "warming up; " + strings.Join(sizes, " ")
+	}
+	return check
+}
+
+// warmup fills the line, station and route caches for modes, retrying until every lookup returns data
+func (sd *tflAPIImpl) warmup() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-sd.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		if sd.warmupOnce(ctx) {
+			sd.warm.Store(true)
+			sd.logger.Info("caches warm", "modes", sd.warmupModes)
+			return
+		}
+		sd.logger.Warn("warm-up incomplete, retrying", "modes", sd.warmupModes, "duration", warmupRetryInterval)
+		select {
+		case <-time.After(warmupRetryInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (sd *tflAPIImpl) warmupOnce(ctx context.Context) bool {
+	complete := true
+	for _, mode := range sd.warmupModes {
+		lines := sd.LinesContext(ctx, mode, false)
+		if len(lines) == 0 {
+			complete = false
+			continue
+		}
+		for _, l := range lines {
+			if len(sd.StationsContext(ctx, l.ID)) == 0 || len(sd.RoutesContext(ctx, l.ID)) == 0 {
+				complete = false
+			}
+		}
+	}
+	return complete
+}
+
+// upstreamHealth remembers the outcome of the most recent TfL calls
+type upstreamHealth struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastErr     error
+}
+
+// record only counts availability failures; TfL answering "not found" means it's up
+func (uh *upstreamHealth) record(err error) {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	if err == nil || !errors.Is(err, ErrUpstreamUnavailable) {
+		uh.lastSuccess = time.Now()
+		return
+	}
+	uh.lastFailure = time.Now()
+	uh.lastErr = err
+}
+
+func (uh *upstreamHealth) check(breakers *circuitBreakers) Check {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	check := Check{Name: "upstream", OK: true}
+	if open := breakers.openEndpoints(); len(open) > 0 {
+		check.OK = false
+		check.Detail = "circuit open for " + strings.Join(open, ", ")
+		return check
+	}
+	switch {
+	case uh.lastSuccess.IsZero() && uh.lastFailure.IsZero():
+		check.Detail = "no TfL calls yet"
+	case uh.lastFailure.After(uh.lastSuccess):
+		check.OK = false
+		check.Detail = fmt.Sprintf("last TfL call failed %s ago: %v", time.Since(uh.lastFailure).Round(time.Second), uh.lastErr)
+	default:
+		check.Detail = fmt.Sprintf("last TfL call succeeded %s ago", time.Since(uh.lastSuccess).Round(time.Second))
+	}
+	return check
+}
+
+func (cbs *circuitBreakers) openEndpoints() []string {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+	var open []string
+	for endpoint, cb := range cbs.breakers {
+		if cb.isOpen() {
+			open = append(open, endpoint)
+		}
+	}
+	sort.Strings(open)
+	return open
+}
@@ -0,0 +1,162 @@
+package tfl
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// iCalendar (RFC 5545) exports of timetables. Times are written as Europe/London wall clock times.
+
+const icsTimeFormat = "20060102T150405"
+
+// icsLondon describes Europe/London for clients that don't know the TZID
+const icsLondon = `BEGIN:VTIMEZONE
+TZID:Europe/London
+BEGIN:DAYLIGHT
+TZOFFSETFROM:+0000
+TZOFFSETTO:+0100
+TZNAME:BST
+DTSTART:19810329T010000
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU
+END:DAYLIGHT
+BEGIN:STANDARD
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0000
+TZNAME:GMT
+DTSTART:19961027T020000
+RRULE:FREQ=YEARLY;BYMONTH=10;BYDAY=-1SU
+END:STANDARD
+END:VTIMEZONE`
+
+type icsEvent struct {
+	uid         string
+	start, end  time.Time
+	summary     string
+	location    string
+	description string
+}
+
+// ICS returns the journey on its service day as a single event lasting until its final stop,
+// with every stop in the description.
+func (stt ScheduledTimeTable) ICS() []byte {
+	departure := stt.DepartureTime.On(stt.ServiceDay)
+	event := icsEvent{
+		uid:      fmt.Sprintf("%s-%s-%s-%d-%s@tfl", stt.From.ID, stt.To.ID, stt.DepartureTime.Destination.ID, stt.DepartureTime.Branch, departure.UTC().Format(icsTimeFormat)),
+		start:    departure,
+		end:      departure,
+		summary:  fmt.Sprintf("%s to %s", stt.From.ShortName(), stt.To.ShortName()),
+		location: stt.From.ShortName(),
+	}
+	stops := make([]string, 0, len(stt.Stops))
+	for _, s := range stt.Stops {
+		at := departure.Add(s.TimeToArrival)
+		stops = append(stops, fmt.Sprintf("%s %s", gmtc.convert(at).Format("15:04"), s.Station.ShortName()))
+		event.end = at
+	}
+	if len(stt.Stops) > 0 {
+		event.summary = fmt.Sprintf("%s to %s", stt.From.ShortName(), stt.Stops[len(stt.Stops)-1].Station.ShortName())
+	}
+	event.description = strings.Join(stops, "\n")
+	return writeICS(fmt.Sprintf("%s to %s at %s", stt.From.ShortName(), stt.To.ShortName(), stt.DepartureTime.ETD()), []icsEvent{event})
+}
+
+// ICS returns the next n departures at or after now as events lasting until they reach their destination,
+// carrying on into the following service day when this one runs out.
+func (sdt ScheduledDepartureTimes) ICS(now time.Time, n int) []byte {
+	events := make([]icsEvent, 0, n)
+	for day := &sdt; day != nil && len(events) < n; day = day.next {
+		for _, dt := range day.DepartureTimes {
+			if len(events) == n {
+				break
+			}
+			departure := dt.On(day.ServiceDay)
+			if departure.Before(now) {
+				continue
+			}
+			event := icsEvent{
+				uid:      fmt.Sprintf("%s-%s-%d-%s@tfl", day.From.ID, dt.Destination.ID, dt.Branch, departure.UTC().Format(icsTimeFormat)),
+				start:    departure,
+				end:      departure,
+				summary:  fmt.Sprintf("%s to %s", day.From.ShortName(), dt.Destination.ShortName()),
+				location: day.From.ShortName(),
+			}
+			if dt.Destination.ID != "" {
+				event.end = day.ServiceDay.At(dt.DestinationArrival)
+				event.description = fmt.Sprintf("Arrives at %s at %s", dt.Destination.ShortName(), gmtc.convert(event.end).Format("15:04"))
+			}
+			events = append(events, event)
+		}
+	}
+	return writeICS(fmt.Sprintf("Departures from %s to %s", sdt.From.ShortName(), sdt.To.ShortName()), events)
+}
+
+func writeICS(name string, events []icsEvent) []byte {
+	var b bytes.Buffer
+	line := func(s string) {
+		b.WriteString(foldICSLine(s))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//arunsworld//tfl//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:" + escapeICSText(name))
+	line("X-WR-TIMEZONE:Europe/London")
+	for _, l := range strings.Split(icsLondon, "\n") {
+		line(l)
+	}
+	stamp := time.Now().UTC().Format(icsTimeFormat) + "Z"
+	for _, e := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + e.uid)
+		line("DTSTAMP:" + stamp)
+		line("DTSTART" + icsDateTime(e.start))
+		line("DTEND" + icsDateTime(e.end))
+		line("SUMMARY:" + escapeICSText(e.summary))
+		line("LOCATION:" + escapeICSText(e.location))
+		if e.description != "" {
+			line("DESCRIPTION:" + escapeICSText(e.description))
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.Bytes()
+}
+
+// icsDateTime is a property value for t in London time. Clients take a wall clock time repeated when the
+// clocks go back to be the first of the two, so times in the second are written in UTC instead.
+func icsDateTime(t time.Time) string {
+	local := gmtc.convert(t).Format(icsTimeFormat)
+	if gmtc.convert(t.Add(-time.Hour)).Format(icsTimeFormat) == local {
+		return ":" + t.UTC().Format(icsTimeFormat) + "Z"
+	}
+	return ";TZID=Europe/London:" + local
+}
+
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// foldICSLine splits lines longer than 75 octets, without breaking UTF-8 sequences
+func foldICSLine(s string) string {
+	if len(s) <= 75 {
+		return s
+	}
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// continuation lines start with a space
+		limit = 74
+	}
+	b.WriteString(s)
+	return b.String()
+}
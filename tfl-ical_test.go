@@ -0,0 +1,143 @@
+package tfl
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeICSText(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"Brixton", "Brixton"},
+		{"Heathrow Terminals 2 & 3", "Heathrow Terminals 2 & 3"},
+		{"King's Cross St. Pancras", "King's Cross St. Pancras"},
+		{"Edgware Road (Circle Line); Paddington, H&C", `Edgware Road (Circle Line)\; Paddington\, H&C`},
+		{`C:\tfl`, `C:\\tfl`},
+		{"00:40 Brixton\n01:12 Walthamstow Central", `00:40 Brixton\n01:12 Walthamstow Central`},
+		// backslashes are escaped before the escapes are added
+		{`\n`, `\\n`},
+	}
+	for _, tc := range tests {
+		if got := escapeICSText(tc.text); got != tc.expected {
+			t.Errorf("%q: expected %q, got %q", tc.text, tc.expected, got)
+		}
+	}
+}
+
+func TestFoldICSLine(t *testing.T) {
+	a := func(n int) string {
+		return strings.Repeat("a", n)
+	}
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+	}{
+		{"short", "SUMMARY:Brixton to Victoria", "SUMMARY:Brixton to Victoria"},
+		{"exactly 75 octets", a(75), a(75)},
+		{"75 octets ending in a two byte rune", a(73) + "é", a(73) + "é"},
+		{"76 octets", a(76), a(75) + "\r\n a"},
+		{"a two byte rune across octet 75", a(74) + "é", a(74) + "\r\n é"},
+		{"a three byte rune across octet 75", a(73) + "€b", a(73) + "\r\n €b"},
+		{"a rune ending at octet 75", a(72) + "€b", a(72) + "€\r\n b"},
+		// continuation lines hold 74 octets after their leading space
+		{"two folds", a(75 + 74 + 1), a(75) + "\r\n " + a(74) + "\r\n a"},
+		{"a rune across the second fold", a(75+73) + "é", a(75) + "\r\n " + a(73) + "\r\n é"},
+	}
+	for _, tc := range tests {
+		got := foldICSLine(tc.line)
+		if got != tc.expected {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.expected, got)
+			continue
+		}
+		for _, l := range strings.Split(got, "\r\n") {
+			if len(l) > 75 || !utf8.ValidString(l) {
+				t.Errorf("%s: folded line %q is %d octets or not UTF-8", tc.name, l, len(l))
+			}
+		}
+		if unfolded := strings.ReplaceAll(got, "\r\n ", ""); unfolded != tc.line {
+			t.Errorf("%s: expected unfolding to give back the line, got %q", tc.name, unfolded)
+		}
+	}
+}
+
+func TestJourneyICSTimes(t *testing.T) {
+	brixton := Station{ID: "940GZZLUBXN", Name: "Brixton Underground Station"}
+	walthamstow := Station{ID: "940GZZLUWWL", Name: "Walthamstow Central Underground Station"}
+	serviceDay := func(month time.Month, day int) ServiceDay {
+		return ServiceDayOf(time.Date(2026, month, day, 12, 0, 0, 0, gmtc.loc))
+	}
+	tests := []struct {
+		name     string
+		day      ServiceDay
+		hour     string
+		duration time.Duration
+		expected string
+	}{
+		{
+			name: "after midnight", day: serviceDay(time.October, 17), hour: "24", duration: time.Minute * 32,
+			expected: `UID:940GZZLUBXN-940GZZLUWWL--0-20261017T234000@tfl
+DTSTART;TZID=Europe/London:20261018T004000
+DTEND;TZID=Europe/London:20261018T011200
+SUMMARY:Brixton to Walthamstow Central
+LOCATION:Brixton
+DESCRIPTION:01:12 Walthamstow Central`,
+		},
+		{
+			// 00:40 GMT is 02:12 BST 32 minutes later
+			name: "the night the clocks go forward", day: serviceDay(time.March, 28), hour: "24", duration: time.Minute * 32,
+			expected: `UID:940GZZLUBXN-940GZZLUWWL--0-20260329T004000@tfl
+DTSTART;TZID=Europe/London:20260329T004000
+DTEND;TZID=Europe/London:20260329T021200
+SUMMARY:Brixton to Walthamstow Central
+LOCATION:Brixton
+DESCRIPTION:02:12 Walthamstow Central`,
+		},
+		{
+			// 25:40 is the first 01:40; an hour later it is 01:40 again
+			name: "the night the clocks go back", day: serviceDay(time.October, 24), hour: "25", duration: time.Hour,
+			expected: `UID:940GZZLUBXN-940GZZLUWWL--0-20261025T004000@tfl
+DTSTART;TZID=Europe/London:20261025T014000
+DTEND:20261025T014000Z
+SUMMARY:Brixton to Walthamstow Central
+LOCATION:Brixton
+DESCRIPTION:01:40 Walthamstow Central`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dt, err := NewDepartureTime(tc.hour, "40")
+			if err != nil {
+				t.Fatal(err)
+			}
+			stt := ScheduledTimeTable{
+				From: brixton, To: walthamstow, ServiceDay: tc.day, DepartureTime: dt,
+				Stops: []ScheduledStop{{Station: walthamstow, TimeToArrival: tc.duration}},
+			}
+			if got := icsEventLines(string(stt.ICS())); got != tc.expected {
+				t.Errorf("expected\n%s\ngot\n%s", tc.expected, got)
+			}
+		})
+	}
+}
+
+// icsEventLines are the unfolded properties of the calendar's event, less its DTSTAMP
+func icsEventLines(ics string) string {
+	var lines []string
+	inEvent := false
+	for _, l := range strings.Split(strings.ReplaceAll(ics, "\r\n ", ""), "\r\n") {
+		switch {
+		case l == "BEGIN:VEVENT":
+			inEvent = true
+		case l == "END:VEVENT":
+			inEvent = false
+		case inEvent && !strings.HasPrefix(l, "DTSTAMP:"):
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
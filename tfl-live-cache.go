@@ -0,0 +1,179 @@
+package tfl
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// liveStaleRetention is how long the last good live data is kept for serving while TfL is unavailable
+const liveStaleRetention = time.Hour
+
+// Staleness marks data served from cache because TfL could not be reached.
+type Staleness struct {
+	StaleAsOf time.Time
+}
+
+func (s Staleness) IsStale() bool {
+	return !s.StaleAsOf.IsZero()
+}
+
+func (s Staleness) StaleAsOfTime() string {
+	return gmtc.convert(s.StaleAsOf).Format("15:04:05")
+}
+
+// CacheStats counts lookups in one of the live data caches.
+type CacheStats struct {
+	// Hits were served from the cache
+	Hits uint64
+	// Misses caused a call to TfL
+	Misses uint64
+	// Coalesced joined a call to TfL already in flight for the same key
+	Coalesced uint64
+}
+
+type liveKey struct {
+	lineID string
+	id     string
+}
+
+type liveResponse[V any] struct {
+	value     V
+	staleAsOf time.Time
+	err       error
+}
+
+type liveRequest[V any] struct {
+	key  liveKey
+	resp chan liveResponse[V]
+}
+
+type liveResult[V any] struct {
+	key liveKey
+	liveResponse[V]
+}
+
+type liveEntry[V any] struct {
+	value     V
+	fetchedAt time.Time
+}
+
+// liveCache keeps real-time results (arrivals, vehicle schedules) for a short TTL
+// and coalesces concurrent requests for the same key into a single TfL call.
+// When TfL is unavailable the last good result is served instead, marked stale.
+// All state is owned by the run goroutine.
+type liveCache[V any] struct {
+	name     string
+	ttl      time.Duration
+	metrics  *tflMetrics
+	fetch    func(ctx context.Context, key liveKey) (V, error)
+	requests chan liveRequest[V]
+	results  chan liveResult[V]
+	done     <-chan struct{}
+	// counters are written by run and read by stats
+	hits, misses, coalesced uint64
+}
+
+func newLiveCache[V any](name string, ttl time.Duration, m *tflMetrics, done <-chan struct{}, fetch func(ctx context.Context, key liveKey) (V, error)) *liveCache[V] {
+	lc := &liveCache[V]{
+		name:     name,
+		ttl:      ttl,
+		metrics:  m,
+		fetch:    fetch,
+		requests: make(chan liveRequest[V]),
+		results:  make(chan liveResult[V]),
+		done:     done,
+	}
+	go lc.run()
+	return lc
+}
+
+func (lc *liveCache[V]) run() {
+	entries := map[liveKey]liveEntry[V]{}
+	waiting := map[liveKey][]chan liveResponse[V]{}
+	purge := time.NewTicker(time.Minute)
+	defer purge.Stop()
+	for {
+		select {
+		case <-lc.done:
+			return
+		case req := <-lc.requests:
+			if e, ok := entries[req.key]; ok && time.Since(e.fetchedAt) < lc.ttl {
+				atomic.AddUint64(&lc.hits, 1)
+				lc.metrics.cacheHit(lc.name)
+				req.resp <- liveResponse[V]{value: e.value}
+				continue
+			}
+			if w, inFlight := waiting[req.key]; inFlight {
+				atomic.AddUint64(&lc.coalesced, 1)
+				lc.metrics.cacheCoalesced(lc.name)
+				waiting[req.key] = append(w, req.resp)
+				continue
+			}
+			atomic.AddUint64(&lc.misses, 1)
+			lc.metrics.cacheMiss(lc.name)
+			waiting[req.key] = []chan liveResponse[V]{req.resp}
+			go lc.fetchInBackground(req.key)
+		case res := <-lc.results:
+			resp := res.liveResponse
+			if res.err == nil {
+				entries[res.key] = liveEntry[V]{value: res.value, fetchedAt: time.Now()}
+				lc.metrics.cacheSize(lc.name, len(entries))
+			} else if e, ok := entries[res.key]; ok && errors.Is(res.err, ErrUpstreamUnavailable) {
+				resp = liveResponse[V]{value: e.value, staleAsOf: e.fetchedAt}
+			}
+			for _, w := range waiting[res.key] {
+				w <- resp
+			}
+			delete(waiting, res.key)
+		case <-purge.C:
+			for k, e := range entries {
+				if time.Since(e.fetchedAt) >= lc.ttl+liveStaleRetention {
+					delete(entries, k)
+				}
+			}
+			lc.metrics.cacheSize(lc.name, len(entries))
+		}
+	}
+}
+
+// fetchInBackground isn't tied to any one caller's context since other callers may be coalesced onto it
+func (lc *liveCache[V]) fetchInBackground(key liveKey) {
+	ctx, cancel := fetchContext(lc.done)
+	defer cancel()
+	v, err := lc.fetch(ctx, key)
+	select {
+	case lc.results <- liveResult[V]{key: key, liveResponse: liveResponse[V]{value: v, err: err}}:
+	case <-lc.done:
+	}
+}
+
+// get returns the value for key; a non-zero time means it is stale data from that time
+func (lc *liveCache[V]) get(ctx context.Context, key liveKey) (V, time.Time, error) {
+	var zero V
+	resp := make(chan liveResponse[V], 1)
+	select {
+	case lc.requests <- liveRequest[V]{key: key, resp: resp}:
+	case <-lc.done:
+		return zero, time.Time{}, ErrClosed
+	case <-ctx.Done():
+		return zero, time.Time{}, ctx.Err()
+	}
+	select {
+	case r := <-resp:
+		return r.value, r.staleAsOf, r.err
+	case <-lc.done:
+		return zero, time.Time{}, ErrClosed
+	case <-ctx.Done():
+		return zero, time.Time{}, ctx.Err()
+	}
+}
+
+func (lc *liveCache[V]) stats() CacheStats {
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&lc.hits),
+		Misses:    atomic.LoadUint64(&lc.misses),
+		Coalesced: atomic.LoadUint64(&lc.coalesced),
+	}
+}
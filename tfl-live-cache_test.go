@@ -0,0 +1,99 @@
+package tfl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLiveCacheCoalescesRequests(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	lc := newLiveCache("arrivals", time.Minute, newTFLMetrics(nil), done, func(ctx context.Context, key liveKey) (string, error) {
+		calls.Add(1)
+		<-release
+		return key.id, nil
+	})
+	key := liveKey{lineID: "victoria", id: "940GZZLUOXC"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, staleAsOf, err := lc.get(context.Background(), key); err != nil || v != key.id || !staleAsOf.IsZero() {
+				t.Errorf("expected fresh %s, got %q, %v, %v", key.id, v, staleAsOf, err)
+			}
+		}()
+	}
+	// every request has joined the one call before it returns
+	for deadline := time.Now().Add(time.Second * 5); lc.stats().Coalesced < 4; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the requests to be coalesced, got %+v", lc.stats())
+		}
+	}
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("expected a single call to TfL, got %d", n)
+	}
+
+	if _, _, err := lc.get(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected the cached value within the TTL, got %d calls", n)
+	}
+	if got := lc.stats(); got != (CacheStats{Hits: 1, Misses: 1, Coalesced: 4}) {
+		t.Errorf("unexpected stats %+v", got)
+	}
+}
+
+func TestLiveCacheServesStaleDataWhenTfLIsUnavailable(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	var calls atomic.Int32
+	var failWith atomic.Value
+	// a zero TTL makes every request call TfL
+	lc := newLiveCache("arrivals", 0, newTFLMetrics(nil), done, func(ctx context.Context, key liveKey) (string, error) {
+		calls.Add(1)
+		if err, ok := failWith.Load().(error); ok {
+			return "", err
+		}
+		return "first", nil
+	})
+	oxfordCircus := liveKey{lineID: "victoria", id: "940GZZLUOXC"}
+
+	before := time.Now()
+	if v, staleAsOf, err := lc.get(context.Background(), oxfordCircus); err != nil || v != "first" || !staleAsOf.IsZero() {
+		t.Fatalf("expected fresh data, got %q, %v, %v", v, staleAsOf, err)
+	}
+	after := time.Now()
+
+	failWith.Store(newUnavailableError("arrivals", errors.New("connection refused")))
+	v, staleAsOf, err := lc.get(context.Background(), oxfordCircus)
+	if err != nil || v != "first" {
+		t.Fatalf("expected the last good data, got %q, %v", v, err)
+	}
+	if staleAsOf.Before(before) || staleAsOf.After(after) {
+		t.Errorf("expected the data to be stale as of when it was fetched, between %v and %v, got %v", before, after, staleAsOf)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected TfL to be tried again, got %d calls", n)
+	}
+
+	// with nothing to fall back on the failure is reported
+	if _, _, err := lc.get(context.Background(), liveKey{lineID: "victoria", id: "940GZZLUBXN"}); !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("expected ErrUpstreamUnavailable without earlier data, got %v", err)
+	}
+	// TfL answering is not a reason to serve old data
+	failWith.Store(error(&APIError{Kind: ErrNotFound, Endpoint: "arrivals", StatusCode: 404}))
+	if _, _, err := lc.get(context.Background(), oxfordCircus); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound rather than stale data, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package tfl
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/arunsworld/tfl/metrics"
+)
+
+// tflMetrics are always recorded; they're only exposed when a registry is passed with WithMetrics
+type tflMetrics struct {
+	upstreamRequests       *metrics.CounterVec
+	upstreamLatency        *metrics.HistogramVec
+	cacheLookups           *metrics.CounterVec
+	cacheEntries           *metrics.GaugeVec
+	requestTimeouts        *metrics.CounterVec
+	timetableInvalidations *metrics.CounterVec
+}
+
+func newTFLMetrics(reg *metrics.Registry) *tflMetrics {
+	if reg == nil {
+		reg = metrics.NewRegistry()
+	}
+	return &tflMetrics{
+		upstreamRequests: reg.NewCounterVec("tfl_upstream_requests_total",
+			"Requests made to the TfL API by endpoint and HTTP status code; code is \"error\" when no response was received.",
+			"endpoint", "code"),
+		upstreamLatency: reg.NewHistogramVec("tfl_upstream_request_duration_seconds",
+			"Latency of requests made to the TfL API by endpoint.",
+			metrics.DefaultBuckets, "endpoint"),
+		cacheLookups: reg.NewCounterVec("tfl_cache_lookups_total",
+			"Cache lookups by cache and result (hit, miss or coalesced).",
+			"cache", "result"),
+		cacheEntries: reg.NewGaugeVec("tfl_cache_entries",
+			"Number of entries held by each cache.",
+			"cache"),
+		requestTimeouts: reg.NewCounterVec("tfl_request_timeouts_total",
+			"Requests that timed out waiting for a cache monitor goroutine, by operation.",
+			"operation"),
+		timetableInvalidations: reg.NewCounterVec("tfl_timetable_invalidations_total",
+			"Cached timetables discarded because they were created on a previous day."),
+	}
+}
+
+func (m *tflMetrics) observeUpstream(endpoint string, statusCode int, err error, took time.Duration) {
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(statusCode)
+	}
+	m.upstreamRequests.WithLabelValues(endpoint, code).Inc()
+	m.upstreamLatency.WithLabelValues(endpoint).Observe(took.Seconds())
+}
+
+func (m *tflMetrics) cacheHit(cache string) {
+	m.cacheLookups.WithLabelValues(cache, "hit").Inc()
+}
+
+func (m *tflMetrics) cacheMiss(cache string) {
+	m.cacheLookups.WithLabelValues(cache, "miss").Inc()
+}
+
+func (m *tflMetrics) cacheCoalesced(cache string) {
+	m.cacheLookups.WithLabelValues(cache, "coalesced").Inc()
+}
+
+func (m *tflMetrics) cacheSize(cache string, n int) {
+	m.cacheEntries.WithLabelValues(cache).Set(float64(n))
+}
+
+func (m *tflMetrics) requestTimeout(operation string) {
+	m.requestTimeouts.WithLabelValues(operation).Inc()
+}
+
+func (m *tflMetrics) timetableInvalidated() {
+	m.timetableInvalidations.WithLabelValues().Inc()
+}
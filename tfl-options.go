@@ -0,0 +1,224 @@
+package tfl
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arunsworld/tfl/metrics"
+)
+
+// Option configures a TFLAPI created with New.
+type Option func(*config)
+
+type config struct {
+	httpClient     *http.Client
+	httpTimeout    time.Duration
+	baseURL        string
+	requestTimeout time.Duration
+	logger         *slog.Logger
+	appID          string
+	appKey         string
+	recordDir      string
+	replayDir      string
+	ttls           cacheTTLs
+	liveTTL        time.Duration
+	rateLimit      float64
+	rateBurst      int
+	retry          retryPolicy
+	// circuit breaker
+	breakerFailures int
+	breakerOpenFor  time.Duration
+	// snapshots
+	snapshotPath     string
+	snapshotInterval time.Duration
+	metrics          *metrics.Registry
+	warmupModes      []string
+	bankHolidaysPath string
+}
+
+func defaultConfig() config {
+	return config{
+		httpTimeout:    time.Second * 5,
+		baseURL:        DefaultBaseURL,
+		requestTimeout: time.Second * 5,
+		logger:         slog.Default(),
+		ttls: cacheTTLs{
+			lines:    time.Hour * 24,
+			stations: time.Hour * 24,
+			routes:   time.Hour * 24,
+		},
+		liveTTL: time.Second * 5,
+		// TfL allows 500 requests a minute with an app_key
+		rateLimit:        500.0 / 60,
+		rateBurst:        10,
+		retry:            newRetryPolicy(3, time.Millisecond*200, time.Second*2),
+		breakerFailures:  5,
+		breakerOpenFor:   time.Second * 30,
+		snapshotInterval: time.Minute * 10,
+	}
+}
+
+// WithHTTPClient sets the client used to talk to the TfL API.
+// The client's own timeout is used; WithHTTPTimeout is ignored.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *config) {
+		cfg.httpClient = c
+	}
+}
+
+// WithHTTPTimeout sets the timeout of the default HTTP client.
+func WithHTTPTimeout(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.httpTimeout = d
+	}
+}
+
+// WithBaseURL points the client at a different TfL API host, eg. a test server.
+func WithBaseURL(baseURL string) Option {
+	return func(cfg *config) {
+		cfg.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithCredentials authenticates every request with a TfL API portal app_id and app_key.
+// Either may be empty; TfL only requires the app_key.
+func WithCredentials(appID, appKey string) Option {
+	return func(cfg *config) {
+		cfg.appID = appID
+		cfg.appKey = appKey
+	}
+}
+
+// WithRecording saves every TfL response (URL, status, body and timestamp) into dir.
+func WithRecording(dir string) Option {
+	return func(cfg *config) {
+		cfg.recordDir = dir
+	}
+}
+
+// WithReplay serves TfL responses from a directory written by WithRecording instead of calling the network.
+// It takes precedence over WithRecording.
+func WithReplay(dir string) Option {
+	return func(cfg *config) {
+		cfg.replayDir = dir
+	}
+}
+
+// WithLinesTTL sets how long cached lines are served before being refreshed in the background.
+func WithLinesTTL(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.ttls.lines = d
+	}
+}
+
+// WithStationsTTL sets how long cached stations are served before being refreshed in the background.
+func WithStationsTTL(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.ttls.stations = d
+	}
+}
+
+// WithRoutesTTL sets how long cached routes are served before being refreshed in the background.
+func WithRoutesTTL(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.ttls.routes = d
+	}
+}
+
+// WithLiveDataTTL sets how long arrivals and vehicle schedules are shared between callers.
+// Concurrent requests for the same station or vehicle always share one TfL call, even with a zero TTL.
+func WithLiveDataTTL(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.liveTTL = d
+	}
+}
+
+// WithRateLimit caps outgoing TfL requests at perSecond, allowing bursts of up to burst requests.
+// Live arrivals and vehicles are served before line data, which is served before timetables.
+// A perSecond of zero removes the cap; requests are still ordered, and paused when TfL answers 429.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(cfg *config) {
+		cfg.rateLimit = perSecond
+		cfg.rateBurst = burst
+	}
+}
+
+// WithRetry retries failed requests up to maxAttempts times in total, with jittered exponential
+// backoff starting at baseDelay and capped at maxDelay. Only availability failures and short
+// rate limits are retried.
+func WithRetry(maxAttempts int, baseDelay, maxDelay time.Duration) Option {
+	return func(cfg *config) {
+		cfg.retry = newRetryPolicy(maxAttempts, baseDelay, maxDelay)
+	}
+}
+
+// WithCircuitBreaker fails requests to an endpoint fast for openFor once it has failed
+// failures times in a row, at least once. Cached data is served while a breaker is open.
+func WithCircuitBreaker(failures int, openFor time.Duration) Option {
+	return func(cfg *config) {
+		cfg.breakerFailures = failures
+		cfg.breakerOpenFor = openFor
+	}
+}
+
+// WithSnapshot persists the line, station, route and timetable caches to path every interval
+// and on Close, and warms the caches from it at startup.
+func WithSnapshot(path string, interval time.Duration) Option {
+	return func(cfg *config) {
+		cfg.snapshotPath = path
+		if interval > 0 {
+			cfg.snapshotInterval = interval
+		}
+	}
+}
+
+// WithMetrics records upstream, cache and timeout metrics into reg.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(cfg *config) {
+		cfg.metrics = reg
+	}
+}
+
+// WithWarmup fetches the lines of each mode, and the stations and routes of every line, at startup.
+// Readiness reports the caches as warm once all of them have been fetched.
+func WithWarmup(modes ...string) Option {
+	return func(cfg *config) {
+		cfg.warmupModes = modes
+	}
+}
+
+// WithBankHolidays reads the England & Wales bank holidays from path, a copy of https://www.gov.uk/bank-holidays.json,
+// instead of the list built into the binary. Timetables run their bank holiday schedules on these dates.
+func WithBankHolidays(path string) Option {
+	return func(cfg *config) {
+		cfg.bankHolidaysPath = path
+	}
+}
+
+// WithRequestTimeout sets how long a call waits on the internal caches before giving up.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.requestTimeout = d
+	}
+}
+
+// WithLogger sets the logger used for diagnostics. Every outgoing TfL request is logged at debug level.
+func WithLogger(l *slog.Logger) Option {
+	return func(cfg *config) {
+		cfg.logger = l
+	}
+}
+
+// New creates a TFLAPI configured by opts.
+func New(opts ...Option) TFLAPI {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.httpClient == nil {
+		cfg.httpClient = &http.Client{Timeout: cfg.httpTimeout}
+	}
+	return newTFLAPIImpl(cfg)
+}
@@ -0,0 +1,183 @@
+package tfl
+
+import (
+	"context"
+	"time"
+)
+
+// defaultRateLimitPause is used when TfL answers 429 without a usable Retry-After
+const defaultRateLimitPause = time.Second * 5
+
+// priority orders requests waiting on the rate limiter; higher goes first
+type priority int
+
+const (
+	priorityBackground priority = iota // timetables, which are fetched once a day
+	priorityNormal                     // lines, stations, routes and status
+	priorityLive                       // arrivals and vehicles, which someone is looking at right now
+	numPriorities
+)
+
+func endpointPriority(endpoint string) priority {
+	switch endpoint {
+	case "arrivals", "line_arrivals", "vehicles":
+		return priorityLive
+	case "timetable":
+		return priorityBackground
+	default:
+		return priorityNormal
+	}
+}
+
+type waitRequest struct {
+	ctx   context.Context
+	ready chan struct{}
+}
+
+// tokenBucket allows perSecond requests on average, in bursts of up to burst
+type tokenBucket struct {
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+}
+
+func newTokenBucket(perSecond float64, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{perSecond: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait returns how long until a token is available
+func (tb *tokenBucket) wait(now time.Time) time.Duration {
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.perSecond
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+	if tb.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tb.tokens) / tb.perSecond * float64(time.Second))
+}
+
+func (tb *tokenBucket) take() {
+	tb.tokens--
+}
+
+// rateLimiter grants every fetch its turn in priority order. Turns are paced by an optional token bucket,
+// and all of them can be paused, eg. when TfL answers 429 with a Retry-After, whether or not there is a bucket.
+// All state is owned by the run goroutine.
+type rateLimiter struct {
+	// bucket is nil when requests aren't capped
+	bucket  *tokenBucket
+	waiters [numPriorities]chan waitRequest
+	pauses  chan time.Time
+	done    <-chan struct{}
+}
+
+// newRateLimiter caps requests at perSecond; a perSecond of zero only orders and pauses them
+func newRateLimiter(perSecond float64, burst int, done <-chan struct{}) *rateLimiter {
+	rl := &rateLimiter{
+		pauses: make(chan time.Time),
+		done:   done,
+	}
+	if perSecond > 0 {
+		rl.bucket = newTokenBucket(perSecond, burst)
+	}
+	for i := range rl.waiters {
+		rl.waiters[i] = make(chan waitRequest)
+	}
+	go rl.run()
+	return rl
+}
+
+func (rl *rateLimiter) run() {
+	var pausedUntil time.Time
+	for {
+		now := time.Now()
+		var wait time.Duration
+		switch {
+		case now.Before(pausedUntil):
+			wait = pausedUntil.Sub(now)
+		case rl.bucket != nil:
+			wait = rl.bucket.wait(now)
+		}
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-rl.done:
+				return
+			case until := <-rl.pauses:
+				if until.After(pausedUntil) {
+					pausedUntil = until
+				}
+			}
+			continue
+		}
+
+		req, ok := rl.nextWaiter()
+		if !ok {
+			select {
+			case req = <-rl.waiters[priorityLive]:
+			case req = <-rl.waiters[priorityNormal]:
+			case req = <-rl.waiters[priorityBackground]:
+			case <-rl.done:
+				return
+			case until := <-rl.pauses:
+				if until.After(pausedUntil) {
+					pausedUntil = until
+				}
+				continue
+			}
+		}
+		if req.ctx.Err() != nil {
+			continue
+		}
+		if rl.bucket != nil {
+			rl.bucket.take()
+		}
+		close(req.ready)
+	}
+}
+
+// nextWaiter returns the highest priority waiter without blocking
+func (rl *rateLimiter) nextWaiter() (waitRequest, bool) {
+	for p := numPriorities - 1; p >= 0; p-- {
+		select {
+		case req := <-rl.waiters[p]:
+			return req, true
+		default:
+		}
+	}
+	return waitRequest{}, false
+}
+
+// wait blocks until the request may be sent or ctx is done
+func (rl *rateLimiter) wait(ctx context.Context, p priority) error {
+	req := waitRequest{ctx: ctx, ready: make(chan struct{})}
+	select {
+	case rl.waiters[p] <- req:
+	case <-rl.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-req.ready:
+		return nil
+	case <-rl.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// pauseFor stops all requests for d
+func (rl *rateLimiter) pauseFor(d time.Duration) {
+	select {
+	case rl.pauses <- time.Now().Add(d):
+	case <-rl.done:
+	}
+}
@@ -0,0 +1,64 @@
+package tfl
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterServesLiveRequestsBeforeTimetables(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	// a token every 50ms keeps the second request waiting long enough to see the order
+	rl := newRateLimiter(20, 1, done)
+	rl.pauseFor(time.Millisecond * 200)
+
+	served := make(chan priority, 2)
+	waitFor := func(p priority) {
+		if err := rl.wait(context.Background(), p); err != nil {
+			t.Error(err)
+		}
+		served <- p
+	}
+	// the timetable warmup queues first
+	go waitFor(priorityBackground)
+	time.Sleep(time.Millisecond * 50)
+	go waitFor(priorityLive)
+
+	first, second := <-served, <-served
+	if first != priorityLive || second != priorityBackground {
+		t.Errorf("expected the arrivals request to be served before the timetable, got %d then %d", first, second)
+	}
+}
+
+func TestRateLimiterWithoutACap(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	rl := newRateLimiter(0, 0, done)
+
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if err := rl.wait(context.Background(), priorityNormal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if took := time.Since(start); took > time.Millisecond*100 {
+		t.Errorf("expected uncapped requests not to wait, took %s", took)
+	}
+
+	rl.pauseFor(time.Millisecond * 200)
+	start = time.Now()
+	if err := rl.wait(context.Background(), priorityLive); err != nil {
+		t.Fatal(err)
+	}
+	if took := time.Since(start); took < time.Millisecond*150 {
+		t.Errorf("expected the pause to hold up requests without a cap, took %s", took)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	rl.pauseFor(time.Second)
+	if err := rl.wait(ctx, priorityLive); err != context.DeadlineExceeded {
+		t.Errorf("expected the caller's deadline while paused, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package tfl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// recording is the on-disk form of a single TfL response
+type recording struct {
+	URL        string          `json:"url"`
+	StatusCode int             `json:"status"`
+	RetryAfter string          `json:"retryAfter,omitempty"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Body       json.RawMessage `json:"body,omitempty"`
+	BodyText   string          `json:"bodyText,omitempty"`
+}
+
+// responseRecorder saves and loads TfL responses, one JSON file per URL.
+// Files are keyed on the URL path and query without credentials or host,
+// so recordings can be replayed against any base URL and with any key.
+type responseRecorder struct {
+	dir string
+}
+
+func newResponseRecorder(dir string) *responseRecorder {
+	return &responseRecorder{dir: dir}
+}
+
+func (rr *responseRecorder) save(rawURL string, resp fetchedResponse) error {
+	key := recordingKey(rawURL)
+	rec := recording{
+		URL:        key,
+		StatusCode: resp.statusCode,
+		RetryAfter: resp.retryAfter,
+		Timestamp:  time.Now(),
+	}
+	body := redactAppKey(resp.body, rawURL)
+	if json.Valid(body) {
+		rec.Body = body
+	} else {
+		rec.BodyText = string(body)
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(rr.dir, 0o755); err != nil {
+		return err
+	}
+	// write then rename so a concurrent replay never sees a partial file
+	tmp, err := os.CreateTemp(rr.dir, ".recording-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), rr.path(key))
+}
+
+func (rr *responseRecorder) load(endpoint, rawURL string) (fetchedResponse, error) {
+	key := recordingKey(rawURL)
+	data, err := os.ReadFile(rr.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fetchedResponse{}, newUnavailableError(endpoint, fmt.Errorf("no recording for %s", key))
+	}
+	if err != nil {
+		return fetchedResponse{}, newUnavailableError(endpoint, err)
+	}
+	rec := recording{}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return fetchedResponse{}, newUnavailableError(endpoint, fmt.Errorf("corrupt recording for %s: %v", key, err))
+	}
+	body := []byte(rec.Body)
+	if rec.BodyText != "" {
+		body = []byte(rec.BodyText)
+	}
+	return fetchedResponse{
+		statusCode: rec.StatusCode,
+		retryAfter: rec.RetryAfter,
+		body:       body,
+	}, nil
+}
+
+func (rr *responseRecorder) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.TrimPrefix(strings.SplitN(key, "?", 2)[0], "/"))
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return filepath.Join(rr.dir, fmt.Sprintf("%s-%s.json", name, hex.EncodeToString(sum[:])[:12]))
+}
+
+// recordingKey is the URL's path and query, less credentials
+func recordingKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	q.Del("app_id")
+	q.Del("app_key")
+	key := u.EscapedPath()
+	if len(q) > 0 {
+		key += "?" + q.Encode()
+	}
+	return key
+}
+
+// redactAppKey removes the URL's app key from body; TfL errors echo the request URI in relativeUri
+func redactAppKey(body []byte, rawURL string) []byte {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return body
+	}
+	key := u.Query().Get("app_key")
+	if key == "" {
+		return body
+	}
+	for _, k := range []string{key, url.QueryEscape(key)} {
+		body = bytes.ReplaceAll(body, []byte(k), []byte("REDACTED"))
+	}
+	return body
+}
@@ -0,0 +1,96 @@
+package tfl_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/arunsworld/tfl/tfltest"
+)
+
+func TestRecordingReplaysWithoutTheAppKey(t *testing.T) {
+	const appKey = "s3cret-key"
+	dir := t.TempDir()
+	srv := newTestServer(t)
+	srv.SetLines("tube", tfltest.Line{ID: "victoria", Name: "Victoria"})
+	srv.SetArrivals("victoria", "940GZZLUOXC",
+		tfltest.Arrival{VehicleID: "201", NaptanID: "940GZZLUOXC", StationName: "Oxford Circus Underground Station", LineID: "victoria", PlatformName: "Northbound - Platform 5", Towards: "Walthamstow Central", TimeToStation: 60, ExpectedArrival: expectedArrival(time.Minute)},
+	)
+	// TfL echoes the request URI, credentials and all, in its errors
+	brixton := tfltest.ArrivalsPath("victoria", "940GZZLUBXN")
+	srv.Set(brixton, tfltest.Fixture{
+		Status: http.StatusNotFound,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   []byte(fmt.Sprintf(`{"httpStatusCode": 404, "relativeUri": "%s?app_id=my-app&app_key=%s", "message": "Not Found"}`, brixton, appKey)),
+	})
+
+	recording := newTestAPI(t, srv, tfl.WithCredentials("my-app", appKey), tfl.WithRecording(dir))
+	recorded, err := recording.LookupLines(context.Background(), "tube", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordedArrivals, err := recording.ArrivalsFor("victoria", "940GZZLUOXC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recording.ArrivalsFor("victoria", "940GZZLUBXN"); !errors.Is(err, tfl.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	for _, u := range srv.Requests() {
+		if u.Query().Get("app_key") != appKey {
+			t.Fatalf("expected the app key to be sent to TfL, got %s", u)
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected a recording per URL, got %d files", len(files))
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(f.Name(), appKey) || strings.Contains(string(data), appKey) {
+			t.Errorf("expected the app key to be left out of %s:\n%s", f.Name(), data)
+		}
+	}
+
+	// replaying needs neither TfL nor the same key
+	requests := len(srv.Requests())
+	replaying := newTestAPI(t, srv, tfl.WithCredentials("my-app", "another-key"), tfl.WithReplay(dir))
+	replayed, err := replaying.LookupLines(context.Background(), "tube", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 1 || replayed[0].ID != recorded[0].ID || replayed[0].Name != recorded[0].Name {
+		t.Errorf("expected the recorded lines %+v, got %+v", recorded, replayed)
+	}
+	arrivals, err := replaying.ArrivalsFor("victoria", "940GZZLUOXC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arrivals.Platforms) != 1 || arrivals.Platforms[0].Arrivals[0].VehicleID != "201" ||
+		!arrivals.Platforms[0].Arrivals[0].ExpectedArrival.Equal(recordedArrivals.Platforms[0].Arrivals[0].ExpectedArrival) {
+		t.Errorf("expected the recorded arrivals %+v, got %+v", recordedArrivals, arrivals)
+	}
+	if _, err := replaying.ArrivalsFor("victoria", "940GZZLUBXN"); !errors.Is(err, tfl.ErrNotFound) {
+		t.Errorf("expected the recorded ErrNotFound, got %v", err)
+	}
+	if _, err := replaying.ArrivalsFor("victoria", "940GZZLUWWL"); !errors.Is(err, tfl.ErrUpstreamUnavailable) {
+		t.Errorf("expected ErrUpstreamUnavailable without a recording, got %v", err)
+	}
+	if n := len(srv.Requests()); n != requests {
+		t.Errorf("expected replaying not to call TfL, got %d more requests", n-requests)
+	}
+}
@@ -0,0 +1,197 @@
+package tfl
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is wrapped (together with ErrUpstreamUnavailable) by calls refused
+// because TfL has been failing and the endpoint's circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+func newRetryPolicy(maxAttempts int, baseDelay, maxDelay time.Duration) retryPolicy {
+	// the first attempt is always made
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return retryPolicy{maxAttempts: maxAttempts, baseDelay: baseDelay, maxDelay: maxDelay}
+}
+
+// backoff is "full jitter" exponential backoff: a random delay up to baseDelay*2^attempt, capped at maxDelay
+func (rp retryPolicy) backoff(attempt int) time.Duration {
+	ceiling := rp.baseDelay << uint(attempt)
+	if ceiling <= 0 || ceiling > rp.maxDelay {
+		ceiling = rp.maxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// retryDelay reports whether err is worth retrying and how long to wait first
+func (rp retryPolicy) retryDelay(attempt int, err error) (time.Duration, bool) {
+	delay := rp.backoff(attempt)
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return 0, false
+	case errors.Is(err, ErrUpstreamUnavailable):
+		return delay, true
+	case errors.Is(err, ErrRateLimited) && errors.As(err, &apiErr):
+		if apiErr.RetryAfter > rp.maxDelay {
+			return 0, false
+		}
+		if apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+		return delay, true
+	default:
+		return 0, false
+	}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker fails fast once an endpoint has failed failureThreshold times in a row.
+// After openFor it lets a single trial request through; its outcome closes or re-opens the breaker.
+type circuitBreaker struct {
+	failureThreshold int
+	openFor          time.Duration
+	now              func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case breakerOpen:
+		if cb.now().Sub(cb.openedAt) < cb.openFor {
+			return false
+		}
+		cb.state = breakerHalfOpen
+		return true
+	case breakerHalfOpen:
+		// a trial request is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+// record notes the outcome of a request let through by allow.
+// Only availability failures count; TfL saying "not found" means TfL is up.
+func (cb *circuitBreaker) record(err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if err == nil || !errors.Is(err, ErrUpstreamUnavailable) {
+		cb.state = breakerClosed
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.state == breakerHalfOpen || cb.failures >= cb.failureThreshold {
+		cb.state = breakerOpen
+		cb.openedAt = cb.now()
+	}
+}
+
+// abandon releases a half-open trial whose outcome is unknown, eg. because the caller went away
+func (cb *circuitBreaker) abandon() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == breakerHalfOpen {
+		cb.state = breakerOpen
+		cb.openedAt = time.Time{}
+	}
+}
+
+func (cb *circuitBreaker) isOpen() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state != breakerClosed
+}
+
+// circuitBreakers holds one breaker per TfL endpoint
+type circuitBreakers struct {
+	failureThreshold int
+	openFor          time.Duration
+	now              func() time.Time
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newCircuitBreakers(failureThreshold int, openFor time.Duration) *circuitBreakers {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	return &circuitBreakers{
+		failureThreshold: failureThreshold,
+		openFor:          openFor,
+		now:              time.Now,
+		breakers:         make(map[string]*circuitBreaker),
+	}
+}
+
+func (cbs *circuitBreakers) forEndpoint(endpoint string) *circuitBreaker {
+	cbs.mu.Lock()
+	defer cbs.mu.Unlock()
+	cb, ok := cbs.breakers[endpoint]
+	if !ok {
+		cb = &circuitBreaker{failureThreshold: cbs.failureThreshold, openFor: cbs.openFor, now: cbs.now}
+		cbs.breakers[endpoint] = cb
+	}
+	return cb
+}
+
+// getWithRetries wraps get in the endpoint's circuit breaker and retries idempotent failures
+func (sf *remoteTFLHTTPFetcher) getWithRetries(ctx context.Context, endpoint, url string) ([]byte, error) {
+	breaker := sf.breakers.forEndpoint(endpoint)
+	var lastErr error
+	for attempt := 0; attempt < sf.retry.maxAttempts; attempt++ {
+		if !breaker.allow() {
+			return nil, &APIError{Kind: ErrUpstreamUnavailable, Endpoint: endpoint, Err: ErrCircuitOpen}
+		}
+		body, err := sf.getOnce(ctx, endpoint, url)
+		if ctx.Err() != nil {
+			breaker.abandon()
+			return nil, newUnavailableError(endpoint, ctx.Err())
+		}
+		breaker.record(err)
+		if err == nil {
+			return body, nil
+		}
+		lastErr = err
+		delay, retryable := sf.retry.retryDelay(attempt, err)
+		if !retryable || attempt == sf.retry.maxAttempts-1 {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, newUnavailableError(endpoint, ctx.Err())
+		}
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,176 @@
+package tfl
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func newTestBreaker(failures int, openFor time.Duration) (*circuitBreaker, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, time.October, 17, 9, 0, 0, 0, time.UTC)}
+	cbs := newCircuitBreakers(failures, openFor)
+	cbs.now = clock.now
+	return cbs.forEndpoint("arrivals"), clock
+}
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	cb, _ := newTestBreaker(3, time.Second*30)
+	unavailable := newUnavailableError("arrivals", errors.New("connection refused"))
+
+	for i := 0; i < 2; i++ {
+		if !cb.allow() {
+			t.Fatalf("expected request %d to be allowed", i+1)
+		}
+		cb.record(unavailable)
+	}
+	// a success resets the count
+	cb.record(nil)
+	for i := 0; i < 2; i++ {
+		cb.record(unavailable)
+	}
+	if !cb.allow() || cb.isOpen() {
+		t.Fatal("expected the breaker to stay closed after two failures in a row")
+	}
+	// TfL answering "not found" means it is up
+	cb.record(&APIError{Kind: ErrNotFound, Endpoint: "arrivals", StatusCode: 404})
+	for i := 0; i < 3; i++ {
+		cb.record(unavailable)
+	}
+	if cb.allow() {
+		t.Fatal("expected the breaker to open after three failures in a row")
+	}
+	if !cb.isOpen() {
+		t.Error("expected the breaker to report open")
+	}
+}
+
+func TestCircuitBreakerLetsOneTrialThroughAfterOpenFor(t *testing.T) {
+	tests := []struct {
+		name  string
+		trial error
+		open  bool
+	}{
+		{"recovers when the trial succeeds", nil, false},
+		{"re-opens when the trial fails", newUnavailableError("arrivals", errors.New("timeout")), true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cb, clock := newTestBreaker(1, time.Second*30)
+			cb.allow()
+			cb.record(newUnavailableError("arrivals", errors.New("timeout")))
+
+			clock.advance(time.Second * 29)
+			if cb.allow() {
+				t.Fatal("expected the breaker to fail fast before openFor has passed")
+			}
+			clock.advance(time.Second)
+			if !cb.allow() {
+				t.Fatal("expected a trial request once openFor has passed")
+			}
+			if cb.allow() {
+				t.Fatal("expected only a single trial request while half-open")
+			}
+			cb.record(tc.trial)
+			if cb.isOpen() != tc.open {
+				t.Fatalf("expected open to be %v after the trial, got %v", tc.open, cb.isOpen())
+			}
+			if allowed := cb.allow(); allowed == tc.open {
+				t.Errorf("expected allow to be %v after the trial, got %v", !tc.open, allowed)
+			}
+			if tc.open {
+				// the re-opened breaker waits openFor again from the trial's failure
+				clock.advance(time.Second * 30)
+				if !cb.allow() {
+					t.Error("expected another trial openFor after the failed one")
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerAbandonedTrialAllowsAnother(t *testing.T) {
+	cb, clock := newTestBreaker(1, time.Second*30)
+	cb.allow()
+	cb.record(newUnavailableError("arrivals", errors.New("timeout")))
+	clock.advance(time.Second * 30)
+	if !cb.allow() {
+		t.Fatal("expected a trial request")
+	}
+	// the caller went away, so the outcome is unknown
+	cb.abandon()
+	if !cb.isOpen() {
+		t.Fatal("expected the breaker to stay open after an abandoned trial")
+	}
+	if !cb.allow() {
+		t.Error("expected another trial straight after an abandoned one")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	rp := newRetryPolicy(3, time.Millisecond*100, time.Second)
+	tests := []struct {
+		name      string
+		err       error
+		retryable bool
+		minDelay  time.Duration
+	}{
+		{"unavailable", newUnavailableError("arrivals", errors.New("reset")), true, 0},
+		{"not found", &APIError{Kind: ErrNotFound, Endpoint: "arrivals", StatusCode: 404}, false, 0},
+		{"bad request", &APIError{Kind: ErrBadRequest, Endpoint: "arrivals", StatusCode: 400}, false, 0},
+		{"circuit open", &APIError{Kind: ErrUpstreamUnavailable, Endpoint: "arrivals", Err: ErrCircuitOpen}, false, 0},
+		{"short Retry-After is waited out", &APIError{Kind: ErrRateLimited, Endpoint: "arrivals", StatusCode: 429, RetryAfter: time.Millisecond * 900}, true, time.Millisecond * 900},
+		{"Retry-After beyond maxDelay is given up on", &APIError{Kind: ErrRateLimited, Endpoint: "arrivals", StatusCode: 429, RetryAfter: time.Second * 2}, false, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			delay, retryable := rp.retryDelay(0, tc.err)
+			if retryable != tc.retryable {
+				t.Fatalf("expected retryable %v, got %v", tc.retryable, retryable)
+			}
+			if retryable && (delay < tc.minDelay || delay > rp.maxDelay) {
+				t.Errorf("expected a delay between %s and %s, got %s", tc.minDelay, rp.maxDelay, delay)
+			}
+		})
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	rp := newRetryPolicy(5, time.Millisecond*100, time.Millisecond*500)
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, time.Millisecond * 100},
+		{1, time.Millisecond * 200},
+		{2, time.Millisecond * 400},
+		{3, time.Millisecond * 500},
+		// shifting this far overflows; the cap still applies
+		{80, time.Millisecond * 500},
+	}
+	for _, tc := range tests {
+		for i := 0; i < 200; i++ {
+			if d := rp.backoff(tc.attempt); d < 0 || d >= tc.ceiling {
+				t.Fatalf("attempt %d: expected a delay in [0, %s), got %s", tc.attempt, tc.ceiling, d)
+			}
+		}
+	}
+	if d := newRetryPolicy(3, 0, 0).backoff(2); d != 0 {
+		t.Errorf("expected no delay without a base delay, got %s", d)
+	}
+	if rp := newRetryPolicy(0, 0, 0); rp.maxAttempts != 1 {
+		t.Errorf("expected the first attempt to always be made, got %d attempts", rp.maxAttempts)
+	}
+}
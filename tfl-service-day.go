@@ -0,0 +1,93 @@
+package tfl
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timetable times are held against a service day, the London date a schedule runs on, in seconds
+// since noon minus 12 hours as GTFS does. That is midnight except on the days the clocks change,
+// where it keeps times after the change on the right instant. Times past 24:00 run into the next morning.
+
+// ServiceDay is the London date a timetable schedule runs on.
+type ServiceDay struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// ServiceDayOf returns the service day of the London date of t.
+func ServiceDayOf(t time.Time) ServiceDay {
+	y, m, d := gmtc.convert(t).Date()
+	return ServiceDay{Year: y, Month: m, Day: d}
+}
+
+// Start is noon minus 12 hours in London, the instant service times count from.
+func (sd ServiceDay) Start() time.Time {
+	return time.Date(sd.Year, sd.Month, sd.Day, 12, 0, 0, 0, gmtc.loc).Add(-time.Hour * 12)
+}
+
+// AddDays returns the service day n days after sd.
+func (sd ServiceDay) AddDays(n int) ServiceDay {
+	return ServiceDayOf(time.Date(sd.Year, sd.Month, sd.Day+n, 12, 0, 0, 0, gmtc.loc))
+}
+
+// noon is always on the London date, unlike Start on the day the clocks go forward
+func (sd ServiceDay) noon() time.Time {
+	return time.Date(sd.Year, sd.Month, sd.Day, 12, 0, 0, 0, gmtc.loc)
+}
+
+// At returns the instant of st on the service day.
+func (sd ServiceDay) At(st ServiceTime) time.Time {
+	return sd.Start().Add(st.SinceStart())
+}
+
+func (sd ServiceDay) Weekday() time.Weekday {
+	return time.Date(sd.Year, sd.Month, sd.Day, 12, 0, 0, 0, time.UTC).Weekday()
+}
+
+func (sd ServiceDay) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", sd.Year, sd.Month, sd.Day)
+}
+
+// ServiceTime is a time on a service day in seconds since its start.
+type ServiceTime int
+
+// TfL timetables run to about 30:00 the morning after
+const maxServiceHour = 47
+
+// ParseServiceTime reads a TfL timetable hour and minute, eg. "24" and "05" for 00:05 the next morning.
+func ParseServiceTime(hour, minute string) (ServiceTime, error) {
+	h, err := strconv.Atoi(hour)
+	if err != nil || h < 0 || h > maxServiceHour {
+		return 0, fmt.Errorf("invalid departure hour %q", hour)
+	}
+	m, err := strconv.Atoi(minute)
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid departure minute %q", minute)
+	}
+	return ServiceTime(h*3600 + m*60), nil
+}
+
+// Hour is 24 or more after midnight.
+func (st ServiceTime) Hour() int {
+	return int(st) / 3600
+}
+
+func (st ServiceTime) Minute() int {
+	return int(st) / 60 % 60
+}
+
+func (st ServiceTime) SinceStart() time.Duration {
+	return time.Duration(st) * time.Second
+}
+
+func (st ServiceTime) Add(d time.Duration) ServiceTime {
+	return st + ServiceTime(d/time.Second)
+}
+
+// String is the time on a 24 hour clock, eg. "00:05" for 24:05.
+func (st ServiceTime) String() string {
+	return fmt.Sprintf("%02d:%02d", st.Hour()%24, st.Minute())
+}
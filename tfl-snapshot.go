@@ -0,0 +1,190 @@
+package tfl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion must be bumped whenever the snapshot layout changes; older snapshots are ignored
+const snapshotVersion = 1
+
+// snapshot is the on-disk form of the static data caches
+type snapshot struct {
+	Version    int
+	SavedAt    time.Time
+	Lines      map[string]snapshotEntry[[]Line]
+	Stations   map[string]snapshotEntry[[]Station]
+	Routes     map[string]snapshotEntry[[]Route]
+	Timetables []snapshotTimetable
+}
+
+type snapshotEntry[V any] struct {
+	Value     V
+	FetchedAt time.Time
+}
+
+// snapshotTimetable stores the raw TfL response, which is parsed again on load
+type snapshotTimetable struct {
+	LineID    string
+	From, To  string
+	FetchedAt time.Time
+	Response  json.RawMessage
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{
+		Version:  snapshotVersion,
+		Lines:    make(map[string]snapshotEntry[[]Line]),
+		Stations: make(map[string]snapshotEntry[[]Station]),
+		Routes:   make(map[string]snapshotEntry[[]Route]),
+	}
+}
+
+// snapshotRequest asks a monitor goroutine to copy its cache into snap and close done
+type snapshotRequest struct {
+	snap *snapshot
+	done chan struct{}
+}
+
+func snapshotEntries[V any](cache map[string]*cachedValue[V]) map[string]snapshotEntry[V] {
+	result := make(map[string]snapshotEntry[V], len(cache))
+	for k, v := range cache {
+		result[k] = snapshotEntry[V]{Value: v.value, FetchedAt: v.fetchedAt}
+	}
+	return result
+}
+
+func restoreEntries[V any](entries map[string]snapshotEntry[V]) map[string]*cachedValue[V] {
+	result := make(map[string]*cachedValue[V], len(entries))
+	for k, v := range entries {
+		result[k] = &cachedValue[V]{value: v.Value, fetchedAt: v.FetchedAt}
+	}
+	return result
+}
+
+func (tm *timetableManager) snapshotInto(snap *snapshot) {
+	for k, v := range tm.cache {
+		if len(v.raw) == 0 {
+			continue
+		}
+		snap.Timetables = append(snap.Timetables, snapshotTimetable{
+			LineID:    k.line,
+			From:      k.from,
+			To:        k.to,
+			FetchedAt: v.createdOn,
+			Response:  v.raw,
+		})
+	}
+}
+
+func (tm *timetableManager) restore(snap *snapshot) {
+	if snap == nil {
+		return
+	}
+	for _, t := range snap.Timetables {
+		tbdw, err := parseTimetable(tm.logger, t.Response, t.LineID, t.From, t.To)
+		if err != nil {
+			tm.logger.Warn("unable to restore timetable from snapshot", "line", t.LineID, "from", t.From, "to", t.To, "error", err)
+			continue
+		}
+		tbdw.createdOn = t.FetchedAt
+		tm.cache[timetableCacheKey{line: t.LineID, from: t.From, to: t.To}] = tbdw
+	}
+}
+
+func loadSnapshot(path string) (*snapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	snap := &snapshot{}
+	if err := json.Unmarshal(data, snap); err != nil {
+		return nil, fmt.Errorf("unable to parse snapshot %s: %v", path, err)
+	}
+	if snap.Version != snapshotVersion {
+		return nil, fmt.Errorf("snapshot %s has version %d, expected %d", path, snap.Version, snapshotVersion)
+	}
+	return snap, nil
+}
+
+func writeSnapshot(path string, snap *snapshot) error {
+	snap.SavedAt = time.Now()
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// collectSnapshot gathers the caches from every monitor goroutine
+func (sd *tflAPIImpl) collectSnapshot() *snapshot {
+	snap := newSnapshot()
+	for _, ch := range []chan snapshotRequest{sd.lineSnapshots, sd.stationSnapshots, sd.routeSnapshots, sd.timetableSnapshots} {
+		req := snapshotRequest{snap: snap, done: make(chan struct{})}
+		ch <- req
+		<-req.done
+	}
+	return snap
+}
+
+type snapshotter struct {
+	path     string
+	interval time.Duration
+	stop     chan struct{}
+	stopped  chan error
+}
+
+func (sd *tflAPIImpl) runSnapshotter() {
+	ticker := time.NewTicker(sd.snapshotter.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := writeSnapshot(sd.snapshotter.path, sd.collectSnapshot()); err != nil {
+				sd.logger.Error("writing snapshot", "path", sd.snapshotter.path, "error", err)
+			}
+		case <-sd.snapshotter.stop:
+			sd.snapshotter.stopped <- writeSnapshot(sd.snapshotter.path, sd.collectSnapshot())
+			return
+		}
+	}
+}
+
+// Close writes a final snapshot, if one is configured, and stops every background goroutine.
+// Calling it again returns the outcome of the first call.
+func (sd *tflAPIImpl) Close() error {
+	sd.closeOnce.Do(func() {
+		// the final snapshot is collected from the monitors, so they are stopped after it
+		if sd.snapshotter != nil {
+			close(sd.snapshotter.stop)
+			sd.closeErr = <-sd.snapshotter.stopped
+		}
+		close(sd.done)
+	})
+	return sd.closeErr
+}
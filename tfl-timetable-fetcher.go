@@ -1,12 +1,12 @@
 package tfl
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"log/slog"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -46,25 +46,29 @@ type tflJourney struct {
 	IntervalId int
 }
 
-func (sf *remoteTFLHTTPFetcher) fetchTimetable(lineID, srcStation, destStation string) (timetableByDayOfWeek, error) {
-	url := sf.timetableURL(lineID, srcStation, destStation)
-	resp, err := sf.c.Get(url)
+func (sf *remoteTFLHTTPFetcher) fetchTimetable(ctx context.Context, lineID, srcStation, destStation string) (timetableBySchedule, error) {
+	body, err := sf.get(ctx, "timetable", sf.timetableURL(lineID, srcStation, destStation))
 	if err != nil {
-		return timetableByDayOfWeek{}, fmt.Errorf("problem fetching timetable data from API: %v", err)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return timetableByDayOfWeek{}, fmt.Errorf("problem reading timetable data from response: %v", err)
+		return timetableBySchedule{}, fmt.Errorf("problem fetching timetable data from API: %w", err)
 	}
+	return parseTimetable(sf.logger, body, lineID, srcStation, destStation)
+}
+
+// parseTimetable keeps the raw response alongside the parsed timetable so it can be snapshotted
+func parseTimetable(logger *slog.Logger, body []byte, lineID, srcStation, destStation string) (timetableBySchedule, error) {
 	tflTW := tflTimetableWrapper{}
 	if err := json.Unmarshal(body, &tflTW); err != nil {
-		return timetableByDayOfWeek{}, fmt.Errorf("problem parsing timetable data from TFL: %v", err)
+		return timetableBySchedule{}, fmt.Errorf("problem parsing timetable data from TFL: %w", newDecodeError("timetable", err))
 	}
-	return tflTimetableWrapperTotimetableByDayOfWeek(tflTW, lineID, srcStation, destStation)
+	result, err := tflTimetableWrapperToTimetableBySchedule(logger, tflTW, lineID, srcStation, destStation)
+	if err != nil {
+		return timetableBySchedule{}, err
+	}
+	result.raw = body
+	return result, nil
 }
 
-func tflTimetableWrapperTotimetableByDayOfWeek(input tflTimetableWrapper, lineID, srcStation, destStation string) (timetableByDayOfWeek, error) {
+func tflTimetableWrapperToTimetableBySchedule(logger *slog.Logger, input tflTimetableWrapper, lineID, srcStation, destStation string) (timetableBySchedule, error) {
 	stopsCache := map[string]Station{}
 	for _, s := range input.Stops {
 		stopsCache[s.Id] = Station{
@@ -73,93 +77,95 @@ func tflTimetableWrapperTotimetableByDayOfWeek(input tflTimetableWrapper, lineID
 		}
 	}
 	if len(input.Timetable.Routes) == 0 {
-		return timetableByDayOfWeek{}, fmt.Errorf("no routes found for %s from %s to %s in timetable", lineID, srcStation, destStation)
-	}
-	if len(input.Timetable.Routes) != 1 {
-		log.Printf("WARNING: timetable for %s from %s to %s, found multiple routes", lineID, srcStation, destStation)
-	}
-	route := input.Timetable.Routes[0]
-	if len(route.Schedules) == 0 {
-		return timetableByDayOfWeek{}, fmt.Errorf("no schedules found for %s from %s to %s in timetable", lineID, srcStation, destStation)
-	}
-	// creating journey cache
-	journeys := map[string]*journey{}
-	for _, si := range route.StationIntervals {
-		stops := make([]stop, 0, len(si.Intervals))
-		for _, interval := range si.Intervals {
-			station, ok := stopsCache[interval.StopId]
-			if !ok {
-				return timetableByDayOfWeek{}, fmt.Errorf("station %s not found in cache while fetching timetable for %s from %s to %s", interval.StopId, lineID, srcStation, destStation)
-			}
-			stops = append(stops, stop{
-				station:       station,
-				timeToArrival: time.Minute * time.Duration(interval.TimeToArrival),
-			})
-		}
-		j := &journey{
-			stops: stops,
-		}
-		journeys[si.ID] = j
+		return timetableBySchedule{}, fmt.Errorf("no routes found for %s from %s to %s in timetable", lineID, srcStation, destStation)
 	}
-
-	result := timetableByDayOfWeek{
+	result := timetableBySchedule{
 		stops:     stopsCache,
 		createdOn: time.Now(),
 	}
-	var defaultTimeTableDetails timeTableDetails
-	for _, schedule := range route.Schedules {
-		scheduledDepartures := make([]DepartureTime, 0, len(schedule.KnownJourneys))
-		scheduleJourneys := make(map[departureTimeKey]*journey)
-		for _, kj := range schedule.KnownJourneys {
-			intervalID := strconv.Itoa(kj.IntervalId)
-			journey, ok := journeys[intervalID]
-			if !ok {
-				return timetableByDayOfWeek{}, fmt.Errorf("didn't find interval ID: %s when processing timetable for %s from %s to %s", intervalID, lineID, srcStation, destStation)
+	// each branch keeps its own schedules; they are merged once each has picked the one for a date
+	for i, route := range input.Timetable.Routes {
+		branch := i + 1
+		// interval IDs are only unique within a route
+		journeys := map[string]*journey{}
+		for _, si := range route.StationIntervals {
+			stops := make([]stop, 0, len(si.Intervals))
+			for _, interval := range si.Intervals {
+				station, ok := stopsCache[interval.StopId]
+				if !ok {
+					return timetableBySchedule{}, fmt.Errorf("station %s not found in cache while fetching timetable for %s from %s to %s", interval.StopId, lineID, srcStation, destStation)
+				}
+				stops = append(stops, stop{
+					station:       station,
+					timeToArrival: time.Minute * time.Duration(interval.TimeToArrival),
+				})
+			}
+			if len(stops) == 0 {
+				return timetableBySchedule{}, fmt.Errorf("interval ID: %s has no stops in timetable for %s from %s to %s", si.ID, lineID, srcStation, destStation)
 			}
-			depTime := DepartureTime{
-				Hour:        kj.Hour,
-				Minute:      kj.Minute,
-				Destination: journey.stops[len(journey.stops)-1].station,
+			journeys[si.ID] = &journey{
+				stops: stops,
 			}
-			depTime.DestinationETA = calculateETAFromDepTime(depTime, journey.stops[len(journey.stops)-1].timeToArrival)
-			scheduledDepartures = append(scheduledDepartures, depTime)
-			scheduleJourneys[departureTimeKey{hour: depTime.Hour, minute: depTime.Minute}] = journey
 		}
-		defaultTimeTableDetails = timeTableDetails{
-			scheduleName:        schedule.Name,
-			scheduledDepartures: scheduledDepartures,
-			journeys:            scheduleJourneys,
+		var schedules []timeTableDetails
+		scheduleIndex := make(map[string]int)
+		for _, schedule := range route.Schedules {
+			si, ok := scheduleIndex[schedule.Name]
+			if !ok {
+				days := parseServiceDays(schedule.Name)
+				if days.count() == 0 && !days.allHolidays {
+					logger.Debug("timetable schedule runs on no weekday", "schedule", schedule.Name, "line", lineID, "from", srcStation, "to", destStation)
+				}
+				si = len(schedules)
+				scheduleIndex[schedule.Name] = si
+				schedules = append(schedules, timeTableDetails{
+					scheduleName: schedule.Name,
+					days:         days,
+					journeys:     make(map[departureTimeKey]*journey),
+				})
+			}
+			ttDetails := &schedules[si]
+			for _, kj := range schedule.KnownJourneys {
+				intervalID := strconv.Itoa(kj.IntervalId)
+				journey, ok := journeys[intervalID]
+				if !ok {
+					return timetableBySchedule{}, fmt.Errorf("didn't find interval ID: %s of branch %d when processing timetable for %s from %s to %s", intervalID, branch, lineID, srcStation, destStation)
+				}
+				departure, err := ParseServiceTime(kj.Hour, kj.Minute)
+				if err != nil {
+					return timetableBySchedule{}, fmt.Errorf("%w when processing timetable for %s from %s to %s", err, lineID, srcStation, destStation)
+				}
+				last := journey.stops[len(journey.stops)-1]
+				depTime := DepartureTime{
+					Time:               departure,
+					Branch:             branch,
+					Destination:        last.station,
+					DestinationArrival: departure.Add(last.timeToArrival),
+				}
+				if _, dup := ttDetails.journeys[depTime.key()]; dup {
+					logger.Debug("dropping duplicate timetable journey", "schedule", schedule.Name, "departure", depTime.ETD(), "branch", branch, "destination", depTime.Destination.ID, "line", lineID)
+					continue
+				}
+				ttDetails.scheduledDepartures = append(ttDetails.scheduledDepartures, depTime)
+				ttDetails.journeys[depTime.key()] = journey
+			}
 		}
-		name := strings.ToLower(schedule.Name)
-		switch {
-		case strings.Contains(name, "friday"):
-			result.fri = defaultTimeTableDetails
-		case strings.Contains(name, "sunday"):
-			result.sun = defaultTimeTableDetails
-		case strings.Contains(name, "monday"):
-			result.monToThu = defaultTimeTableDetails
-		default:
-			result.others = defaultTimeTableDetails
+		if len(schedules) > 0 {
+			for _, ttDetails := range schedules {
+				sortDepartures(ttDetails.scheduledDepartures)
+			}
+			result.branches = append(result.branches, schedules)
 		}
 	}
-	if result.monToThu.journeys != nil {
-		defaultTimeTableDetails = result.monToThu
-	} else {
-		log.Printf("WARNING: no schedules found for Mon-Thu for %s from %s to %s in timetable", lineID, srcStation, destStation)
-		result.monToThu = defaultTimeTableDetails
-	}
-	if result.fri.journeys == nil {
-		log.Printf("WARNING: no schedules found for Fri for %s from %s to %s in timetable", lineID, srcStation, destStation)
-		result.fri = defaultTimeTableDetails
-	}
-	if result.sun.journeys == nil {
-		log.Printf("WARNING: no schedules found for Sun for %s from %s to %s in timetable", lineID, srcStation, destStation)
-		result.sun = defaultTimeTableDetails
-	}
-	if result.others.journeys == nil {
-		log.Printf("WARNING: no schedules found for Sat/Others for %s from %s to %s in timetable", lineID, srcStation, destStation)
-		result.others = defaultTimeTableDetails
+	if len(result.branches) == 0 {
+		return timetableBySchedule{}, fmt.Errorf("no schedules found for %s from %s to %s in timetable", lineID, srcStation, destStation)
 	}
 
 	return result, nil
 }
+
+func sortDepartures(dts []DepartureTime) {
+	sort.SliceStable(dts, func(i, j int) bool {
+		return dts[i].Time < dts[j].Time
+	})
+}
@@ -1,9 +1,11 @@
 package tfl
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,36 +13,60 @@ type ScheduledDepartureTimes struct {
 	From           Station
 	To             Station
 	ScheduleName   string
+	ServiceDay     ServiceDay
 	DepartureTimes []DepartureTime
+	// the following service day's departures, for ICS
+	next *ScheduledDepartureTimes
 }
 
+// DepartureTime is one scheduled departure. Branch is the TfL timetable route it belongs to,
+// numbered from 1; branches may share a departure time.
 type DepartureTime struct {
-	Hour           string
-	Minute         string
-	Destination    Station
-	DestinationETA string
+	Time               ServiceTime
+	Branch             int
+	Destination        Station
+	DestinationArrival ServiceTime
 }
 
-func (dt DepartureTime) ETD() string {
-	hour, err := strconv.Atoi(dt.Hour)
-	if err != nil {
-		log.Printf("error parsing hour: %v", err)
-		return "00:00"
-	}
-	minute, err := strconv.Atoi(dt.Minute)
+// NewDepartureTime creates the departure at a TfL timetable hour and minute, eg. "24" and "05".
+func NewDepartureTime(hour, minute string) (DepartureTime, error) {
+	st, err := ParseServiceTime(hour, minute)
 	if err != nil {
-		log.Printf("error parsing minute: %v", err)
-		return "00:00"
-	}
-	if hour > 23 {
-		hour = hour - 24
+		return DepartureTime{}, err
 	}
-	return fmt.Sprintf("%02d:%02d", hour, minute)
+	return DepartureTime{Time: st}, nil
+}
+
+// Hour is the TfL timetable hour, 24 or more after midnight.
+func (dt DepartureTime) Hour() string {
+	return strconv.Itoa(dt.Time.Hour())
+}
+
+func (dt DepartureTime) Minute() string {
+	return fmt.Sprintf("%02d", dt.Time.Minute())
+}
+
+func (dt DepartureTime) ETD() string {
+	return dt.Time.String()
+}
+
+func (dt DepartureTime) DestinationETA() string {
+	return dt.DestinationArrival.String()
+}
+
+// On returns the departure on service day sd.
+func (dt DepartureTime) On(sd ServiceDay) time.Time {
+	return sd.At(dt.Time)
+}
+
+func (dt DepartureTime) key() departureTimeKey {
+	return departureTimeKey{time: dt.Time, branch: dt.Branch, destination: dt.Destination.ID}
 }
 
 type ScheduledTimeTable struct {
 	From            Station
 	To              Station
+	ServiceDay      ServiceDay
 	DepartureTime   DepartureTime
 	Stops           []ScheduledStop
 	CurrentLocation string
@@ -50,191 +76,265 @@ type ScheduledTimeTable struct {
 type ScheduledStop struct {
 	Station       Station
 	TimeToArrival time.Duration
-	ETA           string
+	ETA           ServiceTime
 	JourneyETA    string
 	JourneyStatus string
+	// JourneyExpectedArrival is when the tracked vehicle is predicted to arrive; zero if it isn't
+	JourneyExpectedArrival time.Time
+}
+
+// Timetable holds every scheduled journey from From towards To, by schedule.
+type Timetable struct {
+	From      Station
+	To        Station
+	Schedules []TimetableSchedule
+}
+
+// TimetableSchedule is a TfL schedule, eg. "Monday - Thursday", and the weekdays it runs on.
+// BankHolidays are the upcoming bank holidays it runs on instead of its weekday's usual schedule.
+type TimetableSchedule struct {
+	Name         string
+	Weekdays     []time.Weekday
+	BankHolidays []time.Time
+	Journeys     []ScheduledJourney
+}
+
+// ScheduledJourney is one departure from From and the stops it calls at after it.
+type ScheduledJourney struct {
+	DepartureTime DepartureTime
+	Stops         []ScheduledStop
 }
 
 type timeTableRequest struct {
-	lineID        string
-	fromStationID string
-	destStationID string
-	weekday       time.Weekday
-	departureTime DepartureTime
-	vehicleID     string
-	// response
-	departureTimeResp chan struct {
-		depTimes ScheduledDepartureTimes
-		err      error
-	}
-	scheduledTimeTableResp chan struct {
-		scheduledTimeTable ScheduledTimeTable
-		err                error
-	}
+	key  timetableCacheKey
+	resp chan timetableResult
+}
+
+type timetableResult struct {
+	timetable timetableBySchedule
+	err       error
 }
 
+// monitorTimetableFetch owns the timetable cache. Timetables are fetched in the background and
+// everything derived from them is worked out by the caller, so the monitor never waits on TfL.
 func (sd *tflAPIImpl) monitorTimetableFetch() {
-	ttMgr := newTimetableManager(sd.fetcher)
-	for req := range sd.timeTableRequests {
-		if req.departureTime.Hour == "" {
-			sdt, err := ttMgr.scheduledDepartureTimesFor(req.lineID, req.fromStationID, req.destStationID, req.weekday)
-			req.departureTimeResp <- struct {
-				depTimes ScheduledDepartureTimes
-				err      error
-			}{
-				depTimes: sdt,
-				err:      err,
+	ttMgr := newTimetableManager(sd.logger, sd.metrics)
+	ttMgr.restore(sd.seed)
+	sd.metrics.cacheSize("timetables", len(ttMgr.cache))
+	pending := map[timetableCacheKey][]chan timetableResult{}
+	fetched := make(chan fetchResult[timetableCacheKey, timetableBySchedule])
+	fetch := func(ctx context.Context, key timetableCacheKey) (timetableBySchedule, error) {
+		return sd.fetcher.fetchTimetable(ctx, key.line, key.from, key.to)
+	}
+	for {
+		select {
+		case <-sd.done:
+			return
+		case p := <-sd.timetablePings:
+			p <- len(ttMgr.cache)
+		case sr := <-sd.timetableSnapshots:
+			ttMgr.snapshotInto(sr.snap)
+			close(sr.done)
+		case req := <-sd.timeTableRequests:
+			if tbdw, ok := ttMgr.current(req.key); ok {
+				req.resp <- timetableResult{timetable: tbdw}
+				continue
 			}
-		} else {
-			stt, err := ttMgr.scheduledTimeTableFor(req.lineID, req.fromStationID, req.destStationID, req.weekday, req.departureTime, req.vehicleID)
-			req.scheduledTimeTableResp <- struct {
-				scheduledTimeTable ScheduledTimeTable
-				err                error
-			}{
-				scheduledTimeTable: stt,
-				err:                err,
+			if _, inFlight := pending[req.key]; inFlight {
+				sd.metrics.cacheCoalesced("timetables")
+			} else {
+				ttMgr.miss(req.key)
+				go backgroundFetch(req.key, fetch, fetched, sd.done)
 			}
+			pending[req.key] = append(pending[req.key], req.resp)
+		case res := <-fetched:
+			tbdw, err := ttMgr.fetched(res.key, res.value, res.err)
+			for _, resp := range pending[res.key] {
+				resp <- timetableResult{timetable: tbdw, err: err}
+			}
+			delete(pending, res.key)
 		}
 	}
 }
 
-func (sd *tflAPIImpl) ScheduledDepartureTimes(lineID, fromStationID, toStationID string, weekday time.Weekday) (ScheduledDepartureTimes, error) {
-	resp := make(chan struct {
-		depTimes ScheduledDepartureTimes
-		err      error
-	}, 1)
+// timetable gets the timetable from fromStationID to toStationID from the monitor; operation names the caller in metrics
+func (sd *tflAPIImpl) timetable(ctx context.Context, operation, lineID, fromStationID, toStationID string) (timetableBySchedule, error) {
+	resp := make(chan timetableResult, 1)
 	req := timeTableRequest{
-		lineID:            lineID,
-		fromStationID:     fromStationID,
-		destStationID:     toStationID,
-		weekday:           weekday,
-		departureTimeResp: resp,
+		key:  timetableCacheKey{line: lineID, from: fromStationID, to: toStationID},
+		resp: resp,
 	}
 	select {
 	case sd.timeTableRequests <- req:
-		result := <-resp
-		return result.depTimes, result.err
-	case <-time.After(time.Second * 5):
-		return ScheduledDepartureTimes{}, fmt.Errorf("timed out waiting on processing request")
+		select {
+		case result := <-resp:
+			return result.timetable, result.err
+		case <-ctx.Done():
+			return timetableBySchedule{}, ctx.Err()
+		}
+	case <-time.After(sd.requestTimeout):
+		sd.metrics.requestTimeout(operation)
+		return timetableBySchedule{}, fmt.Errorf("timed out waiting on processing request")
+	case <-ctx.Done():
+		return timetableBySchedule{}, ctx.Err()
 	}
 }
 
-func (sd *tflAPIImpl) ScheduledTimeTable(lineID, fromStationID, toStationID string,
-	weekday time.Weekday, depTime DepartureTime, vehicleID string) (ScheduledTimeTable, error) {
+func (sd *tflAPIImpl) ScheduledDepartureTimes(lineID, fromStationID, toStationID string, date time.Time) (ScheduledDepartureTimes, error) {
+	return sd.ScheduledDepartureTimesContext(context.Background(), lineID, fromStationID, toStationID, date)
+}
 
-	resp := make(chan struct {
-		scheduledTimeTable ScheduledTimeTable
-		err                error
-	}, 1)
-	req := timeTableRequest{
-		lineID:                 lineID,
-		fromStationID:          fromStationID,
-		destStationID:          toStationID,
-		weekday:                weekday,
-		departureTime:          depTime,
-		vehicleID:              vehicleID,
-		scheduledTimeTableResp: resp,
-	}
-	select {
-	case sd.timeTableRequests <- req:
-		result := <-resp
-		return result.scheduledTimeTable, result.err
-	case <-time.After(time.Second * 5):
-		return ScheduledTimeTable{}, fmt.Errorf("timed out waiting on processing request")
+func (sd *tflAPIImpl) ScheduledDepartureTimesContext(ctx context.Context, lineID, fromStationID, toStationID string, date time.Time) (ScheduledDepartureTimes, error) {
+	tbdw, err := sd.timetable(ctx, "departure_times", lineID, fromStationID, toStationID)
+	if err != nil {
+		return ScheduledDepartureTimes{}, err
 	}
+	return tbdw.scheduledDepartureTimes(fromStationID, toStationID, date, sd.calendar), nil
 }
 
-type departureTimeKey struct {
-	hour, minute string
+func (sd *tflAPIImpl) ScheduledTimeTable(lineID, fromStationID, toStationID string,
+	date time.Time, depTime DepartureTime, vehicleID string) (ScheduledTimeTable, error) {
+	return sd.ScheduledTimeTableContext(context.Background(), lineID, fromStationID, toStationID, date, depTime, vehicleID)
 }
 
-type timetableCacheKey struct {
-	line     string
-	from, to string
+func (sd *tflAPIImpl) ScheduledTimeTableContext(ctx context.Context, lineID, fromStationID, toStationID string,
+	date time.Time, depTime DepartureTime, vehicleID string) (ScheduledTimeTable, error) {
+
+	tbdw, err := sd.timetable(ctx, "timetable", lineID, fromStationID, toStationID)
+	if err != nil {
+		return ScheduledTimeTable{}, err
+	}
+	var vs VehicleSchedule
+	if vehicleID != "" {
+		vs, err = sd.VehicleScheduleForContext(ctx, lineID, vehicleID)
+		if err != nil {
+			sd.logger.Error("fetching vehicle schedule for timetable", "line", lineID, "vehicle", vehicleID, "error", err)
+		}
+	}
+	return tbdw.scheduledTimeTable(fromStationID, toStationID, date, depTime, vehicleID, vs, sd.calendar)
+}
+
+func (sd *tflAPIImpl) Timetable(lineID, fromStationID, toStationID string) (Timetable, error) {
+	return sd.TimetableContext(context.Background(), lineID, fromStationID, toStationID)
 }
 
-func calculateETAFromDepTime(depTime DepartureTime, timeToArrival time.Duration) string {
-	etd, err := time.Parse("15:04", depTime.ETD())
+func (sd *tflAPIImpl) TimetableContext(ctx context.Context, lineID, fromStationID, toStationID string) (Timetable, error) {
+	tbdw, err := sd.timetable(ctx, "timetable", lineID, fromStationID, toStationID)
 	if err != nil {
-		log.Printf("unable to parse ETD: %v", err)
+		return Timetable{}, err
 	}
-	return calculateETA(etd, timeToArrival)
+	return tbdw.timetableOf(fromStationID, toStationID, sd.calendar, time.Now()), nil
 }
 
-func calculateETA(etd time.Time, timeToArrival time.Duration) string {
-	eta := etd.Add(timeToArrival)
-	return eta.Format("15:04")
+// departureTimeKey identifies a journey within a schedule. Two journeys of a branch leaving in the same minute
+// for the same terminus can't be told apart, in URLs or in GTFS trip IDs, so only the first of them is kept.
+type departureTimeKey struct {
+	time        ServiceTime
+	branch      int
+	destination string
+}
+
+type timetableCacheKey struct {
+	line     string
+	from, to string
 }
 
 // only one of this should exist
 // it's methods and operations are not thread-safe therefore should not be called concurrently
 type timetableManager struct {
-	fetcher *remoteTFLHTTPFetcher
-	cache   map[timetableCacheKey]timetableByDayOfWeek
+	logger  *slog.Logger
+	metrics *tflMetrics
+	cache   map[timetableCacheKey]timetableBySchedule
 }
 
-func newTimetableManager(fetcher *remoteTFLHTTPFetcher) *timetableManager {
+func newTimetableManager(logger *slog.Logger, m *tflMetrics) *timetableManager {
 	return &timetableManager{
-		fetcher: fetcher,
-		cache:   make(map[timetableCacheKey]timetableByDayOfWeek),
+		logger:  logger,
+		metrics: m,
+		cache:   make(map[timetableCacheKey]timetableBySchedule),
 	}
 }
 
-func (tm *timetableManager) timetableFor(lineID, srcStationID, destStationID string) (timetableByDayOfWeek, error) {
-	key := timetableCacheKey{line: lineID, from: srcStationID, to: destStationID}
+// current returns the cached timetable for key if it was fetched today
+func (tm *timetableManager) current(key timetableCacheKey) (timetableBySchedule, bool) {
 	tbdw, ok := tm.cache[key]
-	if ok && tbdw.isStillCurrent() {
-		return tbdw, nil
+	if !ok || !tbdw.isStillCurrent() {
+		return timetableBySchedule{}, false
 	}
-	v, err := tm.fetcher.fetchTimetable(lineID, srcStationID, destStationID)
+	tm.metrics.cacheHit("timetables")
+	return tbdw, true
+}
+
+// miss notes that key is about to be fetched
+func (tm *timetableManager) miss(key timetableCacheKey) {
+	if tbdw, ok := tm.cache[key]; ok {
+		tm.metrics.timetableInvalidated()
+		tm.logger.Info("timetable invalidated", "line", key.line, "from", key.from, "to", key.to, "created_on", tbdw.createdOn)
+	}
+	tm.metrics.cacheMiss("timetables")
+}
+
+// fetched stores the outcome of fetching key; yesterday's timetable is served if TfL failed
+func (tm *timetableManager) fetched(key timetableCacheKey, v timetableBySchedule, err error) (timetableBySchedule, error) {
 	if err != nil {
-		return timetableByDayOfWeek{}, err
+		if tbdw, ok := tm.cache[key]; ok {
+			tm.logger.Warn("serving cached timetable", "line", key.line, "from", key.from, "to", key.to, "created_on", tbdw.createdOn, "error", err)
+			return tbdw, nil
+		}
+		return timetableBySchedule{}, err
 	}
 	tm.cache[key] = v
+	tm.metrics.cacheSize("timetables", len(tm.cache))
 	return v, nil
 }
 
-func (tm *timetableManager) scheduledDepartureTimesFor(lineID, srcStationID, destStationID string, weekday time.Weekday) (ScheduledDepartureTimes, error) {
-	tbdw, err := tm.timetableFor(lineID, srcStationID, destStationID)
-	if err != nil {
-		return ScheduledDepartureTimes{}, err
-	}
-	ttDetails := tbdw.timeTableDetailsFor(weekday)
+// scheduledDepartureTimes lists the departures of the service day running at date, and keeps the following day's for ICS
+func (tbs timetableBySchedule) scheduledDepartureTimes(srcStationID, destStationID string, date time.Time, cal *serviceCalendar) ScheduledDepartureTimes {
+	day, ttDetails := tbs.serviceDayAt(date, cal, func(day ServiceDay, ttDetails timeTableDetails) bool {
+		return !date.After(day.At(ttDetails.lastDeparture()))
+	})
+	result := tbs.departureTimesOn(srcStationID, destStationID, day, ttDetails)
+	following := day.AddDays(1)
+	next := tbs.departureTimesOn(srcStationID, destStationID, following, tbs.timeTableDetailsFor(following.noon(), cal))
+	result.next = &next
+	return result
+}
+
+func (tbs timetableBySchedule) departureTimesOn(srcStationID, destStationID string, day ServiceDay, ttDetails timeTableDetails) ScheduledDepartureTimes {
 	return ScheduledDepartureTimes{
-		From:           tbdw.stops[srcStationID],
-		To:             tbdw.stops[destStationID],
+		From:           tbs.stops[srcStationID],
+		To:             tbs.stops[destStationID],
 		ScheduleName:   ttDetails.scheduleName,
+		ServiceDay:     day,
 		DepartureTimes: ttDetails.scheduledDepartures,
-	}, nil
+	}
 }
 
-func (tm *timetableManager) scheduledTimeTableFor(lineID, srcStationID, destStationID string,
-	weekday time.Weekday, departureTime DepartureTime, vehicleID string) (ScheduledTimeTable, error) {
+// scheduledTimeTable compares the journey leaving at departureTime with vs, the tracked vehicle's predictions, if any.
+// The journey is the previous service day's while that one is still running at date.
+func (tbs timetableBySchedule) scheduledTimeTable(srcStationID, destStationID string,
+	date time.Time, departureTime DepartureTime, vehicleID string, vs VehicleSchedule, cal *serviceCalendar) (ScheduledTimeTable, error) {
 
-	tbdw, err := tm.timetableFor(lineID, srcStationID, destStationID)
-	if err != nil {
-		return ScheduledTimeTable{}, err
-	}
-	ttDetails := tbdw.timeTableDetailsFor(weekday)
-	journey, ok := ttDetails.journeys[departureTimeKey{hour: departureTime.Hour, minute: departureTime.Minute}]
+	day, ttDetails := tbs.serviceDayAt(date, cal, func(day ServiceDay, ttDetails timeTableDetails) bool {
+		dt, journey, ok := ttDetails.journeyFor(departureTime)
+		return ok && !date.After(day.At(dt.Time.Add(journey.duration())))
+	})
+	departureTime, journey, ok := ttDetails.journeyFor(departureTime)
 	if !ok {
-		return ScheduledTimeTable{}, fmt.Errorf("no journey found for departure time: %s", departureTime.ETD())
+		return ScheduledTimeTable{}, fmt.Errorf("no journey found for departure time %s: %w", departureTime.ETD(), ErrNotFound)
 	}
 	currentLocation := ""
 	var stops []ScheduledStop
 	if vehicleID == "" {
 		stops = journeyStopsToScheduledStops(journey.stops, departureTime)
 	} else {
-		vs, err := tm.fetcher.fetchVehicleScheduleFor(lineID, vehicleID)
-		if err != nil {
-			log.Printf("error fetching vehicle schedule for line: %s; vehicle: %s during scheduledTimeTableFor", lineID, vehicleID)
-		}
-		stops = journeyStopsToScheduledStopsWithVehicleUpdates(journey.stops, departureTime, vs)
+		stops = journeyStopsToScheduledStopsWithVehicleUpdates(journey.stops, day, departureTime, vs, time.Now())
 		currentLocation = vs.CurrentLocation
 	}
 	return ScheduledTimeTable{
-		From:            tbdw.stops[srcStationID],
-		To:              tbdw.stops[destStationID],
+		From:            tbs.stops[srcStationID],
+		To:              tbs.stops[destStationID],
+		ServiceDay:      day,
 		DepartureTime:   departureTime,
 		Stops:           stops,
 		CurrentLocation: currentLocation,
@@ -242,17 +342,58 @@ func (tm *timetableManager) scheduledTimeTableFor(lineID, srcStationID, destStat
 	}, nil
 }
 
+// timetableOf lists each distinct schedule once, with every weekday it is used on
+// and the bank holidays after now that run it in place of the usual weekday schedule
+func (tbs timetableBySchedule) timetableOf(srcStationID, destStationID string, cal *serviceCalendar, now time.Time) Timetable {
+	result := Timetable{
+		From: tbs.stops[srcStationID],
+		To:   tbs.stops[destStationID],
+	}
+	scheduleIndex := make(map[string]int)
+	scheduleOf := func(ttDetails timeTableDetails) *TimetableSchedule {
+		i, ok := scheduleIndex[ttDetails.scheduleName]
+		if !ok {
+			schedule := TimetableSchedule{Name: ttDetails.scheduleName}
+			for _, dt := range ttDetails.scheduledDepartures {
+				journey := ttDetails.journeys[dt.key()]
+				schedule.Journeys = append(schedule.Journeys, ScheduledJourney{
+					DepartureTime: dt,
+					Stops:         journeyStopsToScheduledStops(journey.stops, dt),
+				})
+			}
+			i = len(result.Schedules)
+			scheduleIndex[ttDetails.scheduleName] = i
+			result.Schedules = append(result.Schedules, schedule)
+		}
+		return &result.Schedules[i]
+	}
+	// days on which no branch runs are left out
+	for _, weekday := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		ttDetails := tbs.timeTableDetailsForWeekday(weekday)
+		if len(ttDetails.scheduledDepartures) == 0 {
+			continue
+		}
+		schedule := scheduleOf(ttDetails)
+		schedule.Weekdays = append(schedule.Weekdays, weekday)
+	}
+	for _, holiday := range cal.upcomingBankHolidays(now) {
+		ttDetails := tbs.timeTableDetailsFor(holiday, cal)
+		if len(ttDetails.scheduledDepartures) == 0 || ttDetails.scheduleName == tbs.timeTableDetailsForWeekday(holiday.Weekday()).scheduleName {
+			continue
+		}
+		schedule := scheduleOf(ttDetails)
+		schedule.BankHolidays = append(schedule.BankHolidays, holiday)
+	}
+	return result
+}
+
 func journeyStopsToScheduledStops(journeyStops []stop, departureTime DepartureTime) []ScheduledStop {
 	stops := make([]ScheduledStop, 0, len(journeyStops))
-	etd, err := time.Parse("15:04", departureTime.ETD())
-	if err != nil {
-		log.Printf("unable to parse ETD: %v", err)
-	}
 	for _, stop := range journeyStops {
 		stops = append(stops, ScheduledStop{
 			Station:       stop.station,
 			TimeToArrival: stop.timeToArrival,
-			ETA:           calculateETA(etd, stop.timeToArrival),
+			ETA:           departureTime.Time.Add(stop.timeToArrival),
 			JourneyETA:    "NA",
 			JourneyStatus: "journeyNA",
 		})
@@ -260,7 +401,9 @@ func journeyStopsToScheduledStops(journeyStops []stop, departureTime DepartureTi
 	return stops
 }
 
-func journeyStopsToScheduledStopsWithVehicleUpdates(journeyStops []stop, departureTime DepartureTime, vs VehicleSchedule) []ScheduledStop {
+// journeyStopsToScheduledStopsWithVehicleUpdates drops the stops the journey left more than 2 minutes before now,
+// unless the vehicle has yet to reach them, and compares the vehicle's predictions with the timetable
+func journeyStopsToScheduledStopsWithVehicleUpdates(journeyStops []stop, day ServiceDay, departureTime DepartureTime, vs VehicleSchedule, now time.Time) []ScheduledStop {
 	if vs.VehicleID == "" {
 		return journeyStopsToScheduledStops(journeyStops, departureTime)
 	}
@@ -271,89 +414,155 @@ func journeyStopsToScheduledStopsWithVehicleUpdates(journeyStops []stop, departu
 		}
 		journeyCache[s.StationID] = s
 	}
-	cutoff, _ := time.Parse("15:04", gmtc.convert(time.Now()).Add(-time.Minute*2).Format("15:04"))
+	cutoff := now.Add(-time.Minute * 2)
+	departure := departureTime.On(day)
 	stops := make([]ScheduledStop, 0, len(journeyStops))
-	etd, err := time.Parse("15:04", departureTime.ETD())
-	if err != nil {
-		log.Printf("unable to parse ETD: %v", err)
-	}
 	firstInclude := false // once we have an include, the remaining stops should be included
 	for _, stop := range journeyStops {
-		eta, jeta, jstatus, include := calculateETAAndJourney(etd, stop.timeToArrival, journeyCache[stop.station.ID], cutoff)
+		vstop := journeyCache[stop.station.ID]
+		jeta, jstatus, include := calculateJourney(departure.Add(stop.timeToArrival), vstop, cutoff)
 		if !firstInclude && !include {
 			continue
 		}
 		firstInclude = true
 		stops = append(stops, ScheduledStop{
-			Station:       stop.station,
-			TimeToArrival: stop.timeToArrival,
-			ETA:           eta,
-			JourneyETA:    jeta,
-			JourneyStatus: jstatus,
+			Station:                stop.station,
+			TimeToArrival:          stop.timeToArrival,
+			ETA:                    departureTime.Time.Add(stop.timeToArrival),
+			JourneyETA:             jeta,
+			JourneyStatus:          jstatus,
+			JourneyExpectedArrival: vstop.ExpectedArrival,
 		})
 	}
 	return stops
 }
 
-func calculateETAAndJourney(etd time.Time, timeToArrival time.Duration, vs VehicleStop, cutoff time.Time) (string, string, string, bool) {
-	eta := etd.Add(timeToArrival)
+func calculateJourney(eta time.Time, vs VehicleStop, cutoff time.Time) (string, string, bool) {
 	if vs.StationID == "" {
-		return eta.Format("15:04"), "NA", "journeyNA", !eta.Before(cutoff)
+		return "NA", "journeyNA", !eta.Before(cutoff)
 	}
-	// journey eta
-	jetaStr := vs.ETATime()
-	jeta, _ := time.Parse("15:04", jetaStr)
 	status := "journeyOK"
-	if jeta.After(eta) {
-		if jeta.Sub(eta) > time.Minute*2 {
-			status = "journeyDelayed"
-		}
+	if vs.ExpectedArrival.Sub(eta) > time.Minute*2 {
+		status = "journeyDelayed"
 	}
-	return eta.Format("15:04"), jetaStr, status, true
+	return vs.ETATime(), status, true
 }
 
-type timetableByDayOfWeek struct {
-	stops     map[string]Station
-	monToThu  timeTableDetails
-	fri       timeTableDetails
-	sun       timeTableDetails
-	others    timeTableDetails
+type timetableBySchedule struct {
+	stops map[string]Station
+	// the schedules of each branch; a date's schedule is picked per branch and the picks merged
+	branches  [][]timeTableDetails
 	createdOn time.Time
+	// raw TfL response; kept for snapshots
+	raw []byte
+}
+
+func (tbs timetableBySchedule) isStillCurrent() bool {
+	return tbs.createdOn.Format("2006-01-02") == time.Now().Format("2006-01-02")
+}
+
+func (tbs timetableBySchedule) timeTableDetailsFor(date time.Time, cal *serviceCalendar) timeTableDetails {
+	return tbs.mergeBranches(func(schedules []timeTableDetails) (timeTableDetails, bool) {
+		return cal.scheduleFor(date, schedules)
+	})
+}
+
+func (tbs timetableBySchedule) timeTableDetailsForWeekday(weekday time.Weekday) timeTableDetails {
+	return tbs.mergeBranches(func(schedules []timeTableDetails) (timeTableDetails, bool) {
+		return scheduleForWeekday(weekday, schedules)
+	})
 }
 
-func (tbdw timetableByDayOfWeek) isStillCurrent() bool {
-	cachedDataFetchDate := tbdw.createdOn.Format("2006-01-02")
-	today := time.Now().Format("2006-01-02")
-	if today != cachedDataFetchDate {
-		log.Printf("INFO: timetable created on %v invalidated today %v", cachedDataFetchDate, today)
-		return false
+// mergeBranches combines the schedule pick chooses from each branch into one, named after all of them,
+// eg. "Monday - Thursday / Monday - Friday". Branches with nothing to pick don't run; a line with a
+// single route falls back to another of its schedules instead.
+func (tbs timetableBySchedule) mergeBranches(pick func([]timeTableDetails) (timeTableDetails, bool)) timeTableDetails {
+	if len(tbs.branches) == 1 {
+		if ttDetails, ok := pick(tbs.branches[0]); ok {
+			return ttDetails
+		}
+		return fallbackSchedule(tbs.branches[0])
 	}
-	return true
+	result := timeTableDetails{journeys: make(map[departureTimeKey]*journey)}
+	var names []string
+	named := make(map[string]bool)
+	for _, schedules := range tbs.branches {
+		ttDetails, ok := pick(schedules)
+		if !ok {
+			continue
+		}
+		if !named[ttDetails.scheduleName] {
+			named[ttDetails.scheduleName] = true
+			names = append(names, ttDetails.scheduleName)
+		}
+		result.scheduledDepartures = append(result.scheduledDepartures, ttDetails.scheduledDepartures...)
+		for k, j := range ttDetails.journeys {
+			result.journeys[k] = j
+		}
+	}
+	result.scheduleName = strings.Join(names, " / ")
+	sortDepartures(result.scheduledDepartures)
+	return result
 }
 
-func (tbdw timetableByDayOfWeek) timeTableDetailsFor(weekday time.Weekday) timeTableDetails {
-	switch weekday {
-	case time.Monday, time.Tuesday, time.Wednesday, time.Thursday:
-		return tbdw.monToThu
-	case time.Friday:
-		return tbdw.fri
-	case time.Saturday:
-		return tbdw.others
-	default:
-		return tbdw.sun
+// serviceDayAt resolves the service day at t, and its schedule. Journeys run past midnight, so that's the day
+// before t's London date while stillRunning says that day's schedule is, and t's London date otherwise.
+func (tbs timetableBySchedule) serviceDayAt(t time.Time, cal *serviceCalendar, stillRunning func(ServiceDay, timeTableDetails) bool) (ServiceDay, timeTableDetails) {
+	today := ServiceDayOf(t)
+	yesterday := today.AddDays(-1)
+	if ttDetails := tbs.timeTableDetailsFor(yesterday.noon(), cal); stillRunning(yesterday, ttDetails) {
+		return yesterday, ttDetails
 	}
+	return today, tbs.timeTableDetailsFor(today.noon(), cal)
 }
 
 type timeTableDetails struct {
 	scheduleName        string
+	days                serviceDays
 	scheduledDepartures []DepartureTime
 	journeys            map[departureTimeKey]*journey
 }
 
+// journeyFor finds the first journey departing at dt.Time, on dt's branch and towards its destination when those are set
+func (ttd timeTableDetails) journeyFor(dt DepartureTime) (DepartureTime, *journey, bool) {
+	for _, candidate := range ttd.scheduledDepartures {
+		if candidate.Time != dt.Time {
+			continue
+		}
+		if dt.Branch != 0 && candidate.Branch != dt.Branch {
+			continue
+		}
+		if dt.Destination.ID != "" && candidate.Destination.ID != dt.Destination.ID {
+			continue
+		}
+		return candidate, ttd.journeys[candidate.key()], true
+	}
+	return dt, nil, false
+}
+
+// lastDeparture is the latest departure of the schedule; zero if it has none
+func (ttd timeTableDetails) lastDeparture() ServiceTime {
+	var last ServiceTime
+	for _, dt := range ttd.scheduledDepartures {
+		if dt.Time > last {
+			last = dt.Time
+		}
+	}
+	return last
+}
+
 type journey struct {
 	stops []stop
 }
 
+// duration is how long the journey takes to reach its final stop
+func (j *journey) duration() time.Duration {
+	if j == nil || len(j.stops) == 0 {
+		return 0
+	}
+	return j.stops[len(j.stops)-1].timeToArrival
+}
+
 type stop struct {
 	station       Station
 	timeToArrival time.Duration
@@ -0,0 +1,357 @@
+package tfl_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arunsworld/tfl"
+	"github.com/arunsworld/tfl/tfltest"
+)
+
+var london = mustLoadLocation("Europe/London")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// newNightTimetableAPI serves a Brixton to Walthamstow Central timetable whose Saturday runs to 25:30
+func newNightTimetableAPI(t *testing.T) tfl.TFLAPI {
+	t.Helper()
+	srv := newTestServer(t)
+	srv.SetTimetable("victoria", "940GZZLUBXN", "940GZZLUWWL", tfltest.Timetable{
+		Stops: []tfltest.TimetableStop{
+			{ID: "940GZZLUBXN", Name: "Brixton Underground Station"},
+			{ID: "940GZZLUWWL", Name: "Walthamstow Central Underground Station"},
+		},
+		Timetable: tfltest.TimetableRouteList{Routes: []tfltest.TimetableRoute{{
+			StationIntervals: []tfltest.StationInterval{{
+				ID:        "0",
+				Intervals: []tfltest.Interval{{StopID: "940GZZLUWWL", TimeToArrival: 32}},
+			}},
+			Schedules: []tfltest.Schedule{
+				{Name: "Monday - Friday", KnownJourneys: []tfltest.KnownJourney{{Hour: "5", Minute: "35"}}},
+				{Name: "Saturday", KnownJourneys: []tfltest.KnownJourney{{Hour: "5", Minute: "30"}, {Hour: "23", Minute: "50"}, {Hour: "24", Minute: "40"}, {Hour: "25", Minute: "30"}}},
+				{Name: "Sunday", KnownJourneys: []tfltest.KnownJourney{{Hour: "5", Minute: "40"}}},
+			},
+		}}},
+	})
+	return newTestAPI(t, srv)
+}
+
+// nextDepartures are the departures of sdt's service day at or after now
+func nextDepartures(sdt tfl.ScheduledDepartureTimes, now time.Time) []time.Time {
+	var result []time.Time
+	for _, dt := range sdt.DepartureTimes {
+		if at := dt.On(sdt.ServiceDay); !at.Before(now) {
+			result = append(result, at.UTC())
+		}
+	}
+	return result
+}
+
+func TestDeparturesAfterMidnightBelongToThePreviousServiceDay(t *testing.T) {
+	api := newNightTimetableAPI(t)
+	tests := []struct {
+		name     string
+		now      time.Time
+		day      string
+		schedule string
+		next     []time.Time
+	}{
+		{
+			name: "00:30 on a Sunday", now: time.Date(2026, time.October, 18, 0, 30, 0, 0, london),
+			day: "2026-10-17", schedule: "Saturday",
+			next: []time.Time{time.Date(2026, time.October, 17, 23, 40, 0, 0, time.UTC), time.Date(2026, time.October, 18, 0, 30, 0, 0, time.UTC)},
+		},
+		{
+			name: "after the last Saturday departure", now: time.Date(2026, time.October, 18, 1, 45, 0, 0, london),
+			day: "2026-10-18", schedule: "Sunday",
+			next: []time.Time{time.Date(2026, time.October, 18, 4, 40, 0, 0, time.UTC)},
+		},
+		{
+			// the clocks go forward at 01:00 GMT, so 25:30 is 02:30 BST
+			name: "the night the clocks go forward", now: time.Date(2026, time.March, 29, 2, 20, 0, 0, london),
+			day: "2026-03-28", schedule: "Saturday",
+			next: []time.Time{time.Date(2026, time.March, 29, 1, 30, 0, 0, time.UTC)},
+		},
+		{
+			// the clocks go back at 02:00 BST, so 25:30 is the first 01:30
+			name: "the night the clocks go back, before the change", now: time.Date(2026, time.October, 25, 0, 15, 0, 0, time.UTC),
+			day: "2026-10-24", schedule: "Saturday",
+			next: []time.Time{time.Date(2026, time.October, 25, 0, 30, 0, 0, time.UTC)},
+		},
+		{
+			name: "the night the clocks go back, after the change", now: time.Date(2026, time.October, 25, 1, 15, 0, 0, time.UTC),
+			day: "2026-10-25", schedule: "Sunday",
+			next: []time.Time{time.Date(2026, time.October, 25, 5, 40, 0, 0, time.UTC)},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sdt, err := api.ScheduledDepartureTimes("victoria", "940GZZLUBXN", "940GZZLUWWL", tc.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sdt.ServiceDay.String() != tc.day || sdt.ScheduleName != tc.schedule {
+				t.Fatalf("expected the %s schedule of %s, got %s of %s", tc.schedule, tc.day, sdt.ScheduleName, sdt.ServiceDay)
+			}
+			next := nextDepartures(sdt, tc.now)
+			if len(next) != len(tc.next) {
+				t.Fatalf("expected departures at %v, got %v", tc.next, next)
+			}
+			for i := range next {
+				if !next[i].Equal(tc.next[i]) {
+					t.Errorf("expected departures at %v, got %v", tc.next, next)
+				}
+			}
+		})
+	}
+}
+
+func TestJourneyAfterMidnightBelongsToThePreviousServiceDay(t *testing.T) {
+	api := newNightTimetableAPI(t)
+	now := time.Date(2026, time.October, 18, 0, 30, 0, 0, london)
+
+	night, err := tfl.NewDepartureTime("24", "40")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stt, err := api.ScheduledTimeTable("victoria", "940GZZLUBXN", "940GZZLUWWL", now, night, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stt.ServiceDay.String() != "2026-10-17" {
+		t.Errorf("expected the 24:40 of Saturday's service, got %s", stt.ServiceDay)
+	}
+
+	// Saturday's 05:30 is long gone, so it's Sunday's that's meant; Sunday has none
+	morning, err := tfl.NewDepartureTime("5", "30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.ScheduledTimeTable("victoria", "940GZZLUBXN", "940GZZLUWWL", now, morning, ""); !errors.Is(err, tfl.ErrNotFound) {
+		t.Errorf("expected no 05:30 on Sunday, got %v", err)
+	}
+}
+
+func TestDeparturesCalendarRollsOverIntoTheNextServiceDay(t *testing.T) {
+	api := newNightTimetableAPI(t)
+	now := time.Date(2026, time.October, 18, 0, 30, 0, 0, london)
+	sdt, err := api.ScheduledDepartureTimes("victoria", "940GZZLUBXN", "940GZZLUWWL", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var starts []string
+	for _, line := range strings.Split(string(sdt.ICS(now, 3)), "\r\n") {
+		if start, ok := strings.CutPrefix(line, "DTSTART;TZID=Europe/London:"); ok {
+			starts = append(starts, start)
+		}
+	}
+	expected := []string{"20261018T004000", "20261018T013000", "20261018T054000"}
+	if strings.Join(starts, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected Saturday's last two departures then Sunday's first, got %v", starts)
+	}
+}
+
+func TestBranchesPickTheirOwnSchedule(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetTimetable("northern", "940GZZLUEUS", "940GZZLUKNG", tfltest.Timetable{
+		Stops: []tfltest.TimetableStop{
+			{ID: "940GZZLUEUS", Name: "Euston Underground Station"},
+			{ID: "940GZZLUKNG", Name: "Kennington Underground Station"},
+			{ID: "940GZZLUMDN", Name: "Morden Underground Station"},
+		},
+		Timetable: tfltest.TimetableRouteList{Routes: []tfltest.TimetableRoute{
+			{
+				StationIntervals: []tfltest.StationInterval{{ID: "0", Intervals: []tfltest.Interval{{StopID: "940GZZLUKNG", TimeToArrival: 12}}}},
+				Schedules: []tfltest.Schedule{
+					{Name: "Monday - Thursday", KnownJourneys: []tfltest.KnownJourney{{Hour: "6", Minute: "0"}}},
+					{Name: "Friday", KnownJourneys: []tfltest.KnownJourney{{Hour: "6", Minute: "5"}}},
+				},
+			},
+			{
+				StationIntervals: []tfltest.StationInterval{{ID: "0", Intervals: []tfltest.Interval{{StopID: "940GZZLUKNG", TimeToArrival: 12}, {StopID: "940GZZLUMDN", TimeToArrival: 30}}}},
+				Schedules: []tfltest.Schedule{
+					{Name: "Monday - Friday", KnownJourneys: []tfltest.KnownJourney{{Hour: "6", Minute: "2"}}},
+				},
+			},
+		}},
+	})
+	api := newTestAPI(t, srv)
+
+	tests := []struct {
+		date     time.Time
+		schedule string
+		etds     []string
+	}{
+		{time.Date(2026, time.October, 19, 12, 0, 0, 0, london), "Monday - Thursday / Monday - Friday", []string{"06:00", "06:02"}},
+		{time.Date(2026, time.October, 23, 12, 0, 0, 0, london), "Friday / Monday - Friday", []string{"06:02", "06:05"}},
+	}
+	for _, tc := range tests {
+		sdt, err := api.ScheduledDepartureTimes("northern", "940GZZLUEUS", "940GZZLUKNG", tc.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var etds []string
+		for _, dt := range sdt.DepartureTimes {
+			etds = append(etds, dt.ETD())
+		}
+		if sdt.ScheduleName != tc.schedule || strings.Join(etds, " ") != strings.Join(tc.etds, " ") {
+			t.Errorf("%s: expected %s departing at %v, got %s departing at %v", tc.date.Weekday(), tc.schedule, tc.etds, sdt.ScheduleName, etds)
+		}
+	}
+
+	morden, err := tfl.NewDepartureTime("6", "02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stt, err := api.ScheduledTimeTable("northern", "940GZZLUEUS", "940GZZLUKNG", tests[0].date, morden, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stt.DepartureTime.Branch != 2 || stt.DepartureTime.Destination.ShortName() != "Morden" {
+		t.Errorf("expected the Monday 06:02 to Morden on branch 2, got %+v", stt.DepartureTime)
+	}
+
+	tt, err := api.Timetable("northern", "940GZZLUEUS", "940GZZLUKNG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for _, schedule := range tt.Schedules {
+		if schedule.Name == tests[0].schedule || schedule.Name == tests[1].schedule {
+			found++
+			if len(schedule.Journeys) != 2 {
+				t.Errorf("expected both branches in %s, got %+v", schedule.Name, schedule.Journeys)
+			}
+		}
+	}
+	if found != 2 {
+		t.Errorf("expected a Monday and a Friday schedule, got %+v", tt.Schedules)
+	}
+}
+
+func TestBranchesOnlyRunOnTheirOwnDays(t *testing.T) {
+	srv := newTestServer(t)
+	stops := []tfltest.TimetableStop{
+		{ID: "940GZZLUEUS", Name: "Euston Underground Station"},
+		{ID: "940GZZLUKNG", Name: "Kennington Underground Station"},
+		{ID: "940GZZLUMDN", Name: "Morden Underground Station"},
+	}
+	toKennington := []tfltest.StationInterval{{ID: "0", Intervals: []tfltest.Interval{{StopID: "940GZZLUKNG", TimeToArrival: 12}}}}
+	toMorden := []tfltest.StationInterval{{ID: "0", Intervals: []tfltest.Interval{{StopID: "940GZZLUKNG", TimeToArrival: 12}, {StopID: "940GZZLUMDN", TimeToArrival: 30}}}}
+	srv.SetTimetable("northern", "940GZZLUEUS", "940GZZLUKNG", tfltest.Timetable{
+		Stops: stops,
+		Timetable: tfltest.TimetableRouteList{Routes: []tfltest.TimetableRoute{
+			{
+				StationIntervals: toKennington,
+				Schedules: []tfltest.Schedule{
+					{Name: "Monday - Friday", KnownJourneys: []tfltest.KnownJourney{{Hour: "6", Minute: "0"}}},
+					{Name: "Sunday", KnownJourneys: []tfltest.KnownJourney{{Hour: "7", Minute: "0"}}},
+				},
+			},
+			{
+				// a weekday-only branch
+				StationIntervals: toMorden,
+				Schedules: []tfltest.Schedule{
+					{Name: "Monday - Friday", KnownJourneys: []tfltest.KnownJourney{{Hour: "6", Minute: "2"}}},
+				},
+			},
+		}},
+	})
+	srv.SetTimetable("northern", "940GZZLUEUS", "940GZZLUMDN", tfltest.Timetable{
+		Stops: stops,
+		Timetable: tfltest.TimetableRouteList{Routes: []tfltest.TimetableRoute{{
+			StationIntervals: toMorden,
+			Schedules: []tfltest.Schedule{
+				{Name: "Monday - Friday", KnownJourneys: []tfltest.KnownJourney{{Hour: "6", Minute: "2"}}},
+			},
+		}}},
+	})
+	api := newTestAPI(t, srv)
+	monday := time.Date(2026, time.October, 19, 12, 0, 0, 0, london)
+	sunday := time.Date(2026, time.October, 25, 12, 0, 0, 0, london)
+
+	tests := []struct {
+		name     string
+		to       string
+		date     time.Time
+		schedule string
+		etds     []string
+	}{
+		{"both branches on a weekday", "940GZZLUKNG", monday, "Monday - Friday", []string{"06:00", "06:02"}},
+		{"the weekday branch doesn't run on Sunday", "940GZZLUKNG", sunday, "Sunday", []string{"07:00"}},
+		// TfL doesn't always publish every day of a single route
+		{"a single route falls back to Monday", "940GZZLUMDN", sunday, "Monday - Friday", []string{"06:02"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sdt, err := api.ScheduledDepartureTimes("northern", "940GZZLUEUS", tc.to, tc.date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var etds []string
+			for _, dt := range sdt.DepartureTimes {
+				etds = append(etds, dt.ETD())
+			}
+			if sdt.ScheduleName != tc.schedule || strings.Join(etds, " ") != strings.Join(tc.etds, " ") {
+				t.Errorf("expected %s departing at %v, got %s departing at %v", tc.schedule, tc.etds, sdt.ScheduleName, etds)
+			}
+		})
+	}
+
+	tt, err := api.Timetable("northern", "940GZZLUEUS", "940GZZLUKNG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, schedule := range tt.Schedules {
+		for _, weekday := range schedule.Weekdays {
+			if weekday == time.Saturday {
+				t.Errorf("expected no Saturday service, got %s", schedule.Name)
+			}
+		}
+	}
+}
+
+func TestDuplicateDeparturesKeepTheFirstJourney(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetTimetable("victoria", "940GZZLUBXN", "940GZZLUWWL", tfltest.Timetable{
+		Stops: []tfltest.TimetableStop{
+			{ID: "940GZZLUBXN", Name: "Brixton Underground Station"},
+			{ID: "940GZZLUVIC", Name: "Victoria Underground Station"},
+			{ID: "940GZZLUWWL", Name: "Walthamstow Central Underground Station"},
+		},
+		Timetable: tfltest.TimetableRouteList{Routes: []tfltest.TimetableRoute{{
+			StationIntervals: []tfltest.StationInterval{
+				{ID: "0", Intervals: []tfltest.Interval{{StopID: "940GZZLUVIC", TimeToArrival: 6}, {StopID: "940GZZLUWWL", TimeToArrival: 32}}},
+				{ID: "1", Intervals: []tfltest.Interval{{StopID: "940GZZLUWWL", TimeToArrival: 30}}},
+			},
+			Schedules: []tfltest.Schedule{
+				{Name: "Monday - Friday", KnownJourneys: []tfltest.KnownJourney{{Hour: "6", Minute: "0", IntervalID: 0}, {Hour: "6", Minute: "0", IntervalID: 1}}},
+			},
+		}}},
+	})
+	api := newTestAPI(t, srv)
+	monday := time.Date(2026, time.October, 19, 12, 0, 0, 0, london)
+
+	sdt, err := api.ScheduledDepartureTimes("victoria", "940GZZLUBXN", "940GZZLUWWL", monday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sdt.DepartureTimes) != 1 {
+		t.Fatalf("expected the duplicate 06:00 to be dropped, got %+v", sdt.DepartureTimes)
+	}
+	stt, err := api.ScheduledTimeTable("victoria", "940GZZLUBXN", "940GZZLUWWL", monday, sdt.DepartureTimes[0], "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stt.Stops) != 2 || stt.Stops[0].Station.ShortName() != "Victoria" {
+		t.Errorf("expected the first journey, calling at Victoria, got %+v", stt.Stops)
+	}
+}
@@ -1,10 +1,10 @@
 package tfl
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"log/slog"
 	"sort"
 	"time"
 )
@@ -22,20 +22,14 @@ type tflVehicleArrivals struct {
 	ExpectedArrival string
 }
 
-func (sf *remoteTFLHTTPFetcher) fetchVehicleScheduleFor(lineID, vehicleID string) (VehicleSchedule, error) {
-	url := sf.vehiclesURL(vehicleID)
-	resp, err := sf.c.Get(url)
+func (sf *remoteTFLHTTPFetcher) fetchVehicleScheduleFor(ctx context.Context, lineID, vehicleID string) (VehicleSchedule, error) {
+	body, err := sf.get(ctx, "vehicles", sf.vehiclesURL(vehicleID))
 	if err != nil {
-		return VehicleSchedule{}, fmt.Errorf("problem fetching data from API: %v", err)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return VehicleSchedule{}, fmt.Errorf("problem reading data from response: %v", err)
+		return VehicleSchedule{}, fmt.Errorf("problem fetching data from API: %w", err)
 	}
 	_tva := []tflVehicleArrivals{}
 	if err := json.Unmarshal(body, &_tva); err != nil {
-		return VehicleSchedule{}, fmt.Errorf("problem parsing response data from TFL: %v", err)
+		return VehicleSchedule{}, fmt.Errorf("problem parsing response data from TFL: %w", newDecodeError("vehicles", err))
 	}
 	tva := make([]tflVehicleArrivals, 0, len(_tva))
 	for _, station := range _tva {
@@ -83,7 +77,7 @@ func calculateVehicleStops(input []tflVehicleArrivals) []VehicleStop {
 	for _, station := range input {
 		expectedArrival, err := time.Parse(time.RFC3339, station.ExpectedArrival)
 		if err != nil {
-			log.Printf("unable to parse %s as date: %v", station.ExpectedArrival, err)
+			slog.Warn("unable to parse expected arrival", "expected_arrival", station.ExpectedArrival, "station", station.NaptanId, "error", err)
 		}
 		result = append(result, VehicleStop{
 			StationID:       station.NaptanId,
@@ -1,6 +1,7 @@
 package tfl
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -11,6 +12,7 @@ type VehicleSchedule struct {
 	Destination     string
 	CurrentLocation string
 	Stops           []VehicleStop
+	Staleness
 }
 
 func (vs VehicleSchedule) CleansedCurrentLocation() string {
@@ -36,5 +38,11 @@ func (s VehicleStop) ETATime() string {
 }
 
 func (sd *tflAPIImpl) VehicleScheduleFor(lineID, vehicleID string) (VehicleSchedule, error) {
-	return sd.fetcher.fetchVehicleScheduleFor(lineID, vehicleID)
+	return sd.VehicleScheduleForContext(context.Background(), lineID, vehicleID)
+}
+
+func (sd *tflAPIImpl) VehicleScheduleForContext(ctx context.Context, lineID, vehicleID string) (VehicleSchedule, error) {
+	vs, staleAsOf, err := sd.vehicles.get(ctx, liveKey{lineID: lineID, id: vehicleID})
+	vs.StaleAsOf = staleAsOf
+	return vs, err
 }
@@ -1,27 +1,58 @@
 package tfl
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var TFLAPIGlobal TFLAPI = newTFLAPIImpl()
-
+// TFLAPI is the read API over the TfL network.
+// Every method has a Context variant; the plain methods use context.Background().
 type TFLAPI interface {
 	Lines(mode string, includeStatus bool) []Line
+	LinesContext(ctx context.Context, mode string, includeStatus bool) []Line
 	LineDetails(mode string, lineID string) Line
+	LineDetailsContext(ctx context.Context, mode string, lineID string) Line
 	Stations(mode string) []Station
+	StationsContext(ctx context.Context, mode string) []Station
 	Routes(mode string) []Route
-	ScheduledDepartureTimes(lineID, fromStationID, toStationID string, weekday time.Weekday) (ScheduledDepartureTimes, error)
-	ScheduledTimeTable(lineID, fromStationID, toStationID string, weekday time.Weekday, depTime DepartureTime, vehicleID string) (ScheduledTimeTable, error)
+	RoutesContext(ctx context.Context, mode string) []Route
+	// LookupLines and LookupRoutes are LinesContext and RoutesContext reporting why nothing could be returned.
+	LookupLines(ctx context.Context, mode string, includeStatus bool) ([]Line, error)
+	LookupRoutes(ctx context.Context, lineID string) ([]Route, error)
+	// ScheduledDepartureTimes and ScheduledTimeTable use the service day running at date, and its schedule, including on bank holidays.
+	// That is the previous day while its journeys after midnight are still to leave, or for a journey, to arrive.
+	ScheduledDepartureTimes(lineID, fromStationID, toStationID string, date time.Time) (ScheduledDepartureTimes, error)
+	ScheduledDepartureTimesContext(ctx context.Context, lineID, fromStationID, toStationID string, date time.Time) (ScheduledDepartureTimes, error)
+	ScheduledTimeTable(lineID, fromStationID, toStationID string, date time.Time, depTime DepartureTime, vehicleID string) (ScheduledTimeTable, error)
+	ScheduledTimeTableContext(ctx context.Context, lineID, fromStationID, toStationID string, date time.Time, depTime DepartureTime, vehicleID string) (ScheduledTimeTable, error)
+	// Timetable returns every scheduled journey from fromStationID towards toStationID, by schedule.
+	Timetable(lineID, fromStationID, toStationID string) (Timetable, error)
+	TimetableContext(ctx context.Context, lineID, fromStationID, toStationID string) (Timetable, error)
 	ArrivalsFor(lineID, stationID string) (Arrivals, error)
+	ArrivalsForContext(ctx context.Context, lineID, stationID string) (Arrivals, error)
 	VehicleScheduleFor(lineID, vehicleID string) (VehicleSchedule, error)
+	VehicleScheduleForContext(ctx context.Context, lineID, vehicleID string) (VehicleSchedule, error)
+	// LineArrivals returns the predicted stops of every vehicle on a line.
+	LineArrivals(lineID string) (LineArrivals, error)
+	LineArrivalsContext(ctx context.Context, lineID string) (LineArrivals, error)
+	// CacheStats reports hits and misses of the live data caches, keyed by "arrivals", "line_arrivals", "vehicles" and "status".
+	CacheStats() map[string]CacheStats
+	// Liveness reports whether the background goroutines are responsive.
+	Liveness(ctx context.Context) []Check
+	// Readiness reports whether the caches are warm and TfL is answering.
+	Readiness(ctx context.Context) []Check
+	// Close releases background resources and writes a final snapshot, if configured.
+	Close() error
 }
 
 type Line struct {
@@ -32,6 +63,7 @@ type Line struct {
 
 type Status struct {
 	StatusDescriptions []string
+	Staleness
 }
 
 type Route struct {
@@ -66,16 +98,48 @@ func (s Station) ShortName() string {
 
 type tflAPIImpl struct {
 	fetcher           *remoteTFLHTTPFetcher
+	logger            *slog.Logger
+	requestTimeout    time.Duration
+	ttls              cacheTTLs
 	lineRequests      chan lineRequest
 	stationRequests   chan stationRequest
 	routeRequests     chan routeRequest
 	timeTableRequests chan timeTableRequest
+	calendar          *serviceCalendar
+	arrivals          *liveCache[Arrivals]
+	lineArrivals      *liveCache[LineArrivals]
+	vehicles          *liveCache[VehicleSchedule]
+	statuses          *liveCache[map[string]Status]
+	// snapshots
+	seed               *snapshot
+	snapshotter        *snapshotter
+	lineSnapshots      chan snapshotRequest
+	stationSnapshots   chan snapshotRequest
+	routeSnapshots     chan snapshotRequest
+	timetableSnapshots chan snapshotRequest
+	metrics            *tflMetrics
+	// health
+	linePings      chan monitorPing
+	stationPings   chan monitorPing
+	routePings     chan monitorPing
+	timetablePings chan monitorPing
+	warmupModes    []string
+	warm           atomic.Bool
+	// closed by Close to stop every background goroutine
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
 }
 
 type lineRequest struct {
 	mode   string
 	lineID string
-	resp   chan []Line
+	resp   chan lineResponse
+}
+
+type lineResponse struct {
+	lines []Line
+	err   error
 }
 
 type stationRequest struct {
@@ -85,242 +149,650 @@ type stationRequest struct {
 
 type routeRequest struct {
 	lineID string
-	resp   chan []Route
+	resp   chan routeResponse
+}
+
+type routeResponse struct {
+	routes []Route
+	err    error
 }
 
-func newTFLAPIImpl() *tflAPIImpl {
+func newTFLAPIImpl(cfg config) *tflAPIImpl {
+	m := newTFLMetrics(cfg.metrics)
+	done := make(chan struct{})
 	result := &tflAPIImpl{
-		lineRequests:      make(chan lineRequest),
-		stationRequests:   make(chan stationRequest),
-		routeRequests:     make(chan routeRequest),
-		timeTableRequests: make(chan timeTableRequest),
-		fetcher:           newStaticFetcher(),
+		done:               done,
+		lineRequests:       make(chan lineRequest),
+		stationRequests:    make(chan stationRequest),
+		routeRequests:      make(chan routeRequest),
+		timeTableRequests:  make(chan timeTableRequest),
+		fetcher:            newRemoteFetcher(cfg, m, done),
+		logger:             cfg.logger,
+		requestTimeout:     cfg.requestTimeout,
+		ttls:               cfg.ttls,
+		lineSnapshots:      make(chan snapshotRequest),
+		stationSnapshots:   make(chan snapshotRequest),
+		routeSnapshots:     make(chan snapshotRequest),
+		timetableSnapshots: make(chan snapshotRequest),
+		metrics:            m,
+		linePings:          make(chan monitorPing),
+		stationPings:       make(chan monitorPing),
+		routePings:         make(chan monitorPing),
+		timetablePings:     make(chan monitorPing),
+		warmupModes:        cfg.warmupModes,
+		calendar:           mustParseEmbeddedBankHolidays(),
+	}
+	if cfg.bankHolidaysPath != "" {
+		cal, err := loadBankHolidays(cfg.bankHolidaysPath)
+		if err != nil {
+			result.logger.Warn("using embedded bank holidays", "path", cfg.bankHolidaysPath, "error", err)
+		} else {
+			result.calendar = cal
+		}
 	}
+	if cfg.snapshotPath != "" {
+		seed, err := loadSnapshot(cfg.snapshotPath)
+		if err != nil {
+			result.logger.Warn("starting with cold caches", "snapshot", cfg.snapshotPath, "error", err)
+		}
+		result.seed = seed
+		result.snapshotter = &snapshotter{
+			path:     cfg.snapshotPath,
+			interval: cfg.snapshotInterval,
+			stop:     make(chan struct{}),
+			stopped:  make(chan error, 1),
+		}
+	}
+	result.arrivals = newLiveCache("arrivals", cfg.liveTTL, m, done, func(ctx context.Context, k liveKey) (Arrivals, error) {
+		return result.fetcher.fetchArrivals(ctx, k.lineID, k.id)
+	})
+	result.vehicles = newLiveCache("vehicles", cfg.liveTTL, m, done, func(ctx context.Context, k liveKey) (VehicleSchedule, error) {
+		return result.fetcher.fetchVehicleScheduleFor(ctx, k.lineID, k.id)
+	})
+	result.lineArrivals = newLiveCache("line_arrivals", cfg.liveTTL, m, done, func(ctx context.Context, k liveKey) (LineArrivals, error) {
+		return result.fetcher.fetchLineArrivals(ctx, k.lineID)
+	})
+	result.statuses = newLiveCache("status", cfg.liveTTL, m, done, func(ctx context.Context, k liveKey) (map[string]Status, error) {
+		return result.fetcher.fetchStatus(ctx, k.id)
+	})
 	go result.monitorLineFetch()
 	go result.monitorStationFetch()
 	go result.monitorRouteFetch()
 	go result.monitorTimetableFetch()
+	if result.snapshotter != nil {
+		go result.runSnapshotter()
+	}
+	if len(result.warmupModes) > 0 {
+		go result.warmup()
+	}
 	return result
 }
 
 func (sd *tflAPIImpl) monitorLineFetch() {
-	lines := map[string][]Line{}
+	lines := map[string]*cachedValue[[]Line]{}
 	linesCache := make(map[string]Line)
-	for req := range sd.lineRequests {
-		mode := req.mode
-		linesForMode, ok := lines[mode]
-		if ok {
-			respondToLineRequest(req, linesForMode, linesCache)
-			continue
-		}
-		_lines, err := sd.fetcher.fetchLines(req.mode)
-		if err != nil {
-			log.Printf("ERROR fetching lines: %v", err)
-			req.resp <- []Line{}
-			continue
+	if sd.seed != nil {
+		lines = restoreEntries(sd.seed.Lines)
+		for _, v := range lines {
+			for _, l := range v.value {
+				linesCache[l.ID] = l
+			}
 		}
-		lines[mode] = _lines
-		for _, l := range _lines {
-			linesCache[l.ID] = l
+		sd.metrics.cacheSize("lines", len(linesCache))
+	}
+	// requests waiting on a fetch of their mode
+	pending := map[string][]lineRequest{}
+	fetched := make(chan fetchResult[string, []Line])
+	refreshed := make(chan fetchResult[string, []Line])
+	for {
+		select {
+		case <-sd.done:
+			return
+		case p := <-sd.linePings:
+			p <- len(lines)
+		case sr := <-sd.lineSnapshots:
+			sr.snap.Lines = snapshotEntries(lines)
+			close(sr.done)
+		case req := <-sd.lineRequests:
+			mode := req.mode
+			linesForMode, ok := lines[mode]
+			if ok {
+				sd.metrics.cacheHit("lines")
+				respondToLineRequest(req, linesForMode.value, linesCache)
+				if linesForMode.needsRefresh(sd.ttls.lines) {
+					linesForMode.refreshing = true
+					go backgroundFetch(mode, sd.fetcher.fetchLines, refreshed, sd.done)
+				}
+				continue
+			}
+			if _, inFlight := pending[mode]; inFlight {
+				sd.metrics.cacheCoalesced("lines")
+			} else {
+				sd.metrics.cacheMiss("lines")
+				go backgroundFetch(mode, sd.fetcher.fetchLines, fetched, sd.done)
+			}
+			pending[mode] = append(pending[mode], req)
+		case res := <-fetched:
+			waiting := pending[res.key]
+			delete(pending, res.key)
+			if res.err != nil {
+				sd.logger.Error("fetching lines", "mode", res.key, "error", res.err)
+				for _, req := range waiting {
+					req.resp <- lineResponse{lines: []Line{}, err: res.err}
+				}
+				continue
+			}
+			if len(res.value) > 0 {
+				lines[res.key] = newCachedValue(res.value)
+				for _, l := range res.value {
+					linesCache[l.ID] = l
+				}
+				sd.metrics.cacheSize("lines", len(linesCache))
+			}
+			for _, req := range waiting {
+				respondToLineRequest(req, res.value, linesCache)
+			}
+		case res := <-refreshed:
+			entry := lines[res.key]
+			entry.refreshing = false
+			if res.err != nil || len(res.value) == 0 {
+				sd.logger.Error("refreshing lines, keeping cached data", "mode", res.key, "error", res.err)
+				continue
+			}
+			lines[res.key] = newCachedValue(res.value)
+			for _, l := range res.value {
+				linesCache[l.ID] = l
+			}
+			sd.metrics.cacheSize("lines", len(linesCache))
 		}
-		respondToLineRequest(req, _lines, linesCache)
 	}
 }
 
 func respondToLineRequest(req lineRequest, lines []Line, linesCache map[string]Line) {
 	if req.lineID == "" {
-		req.resp <- lines
+		req.resp <- lineResponse{lines: lines}
 	} else {
 		line, ok := linesCache[req.lineID]
 		if ok {
-			req.resp <- []Line{line}
+			req.resp <- lineResponse{lines: []Line{line}}
 		} else {
-			req.resp <- []Line{
+			req.resp <- lineResponse{lines: []Line{
 				{ID: req.lineID, Name: req.lineID},
-			}
+			}}
 		}
 	}
 }
 
 func (sd *tflAPIImpl) monitorStationFetch() {
-	stations := map[string][]Station{}
-	for req := range sd.stationRequests {
-		v, ok := stations[req.lineID]
-		if ok {
-			req.resp <- v
-			continue
-		}
-		_stations, err := sd.fetcher.fetchStation(req.lineID)
-		if err != nil {
-			log.Printf("ERROR fetching stations: %v", err)
-			req.resp <- []Station{}
-			continue
+	stations := map[string]*cachedValue[[]Station]{}
+	if sd.seed != nil {
+		stations = restoreEntries(sd.seed.Stations)
+		sd.metrics.cacheSize("stations", len(stations))
+	}
+	pending := map[string][]stationRequest{}
+	fetched := make(chan fetchResult[string, []Station])
+	refreshed := make(chan fetchResult[string, []Station])
+	for {
+		select {
+		case <-sd.done:
+			return
+		case p := <-sd.stationPings:
+			p <- len(stations)
+		case sr := <-sd.stationSnapshots:
+			sr.snap.Stations = snapshotEntries(stations)
+			close(sr.done)
+		case req := <-sd.stationRequests:
+			v, ok := stations[req.lineID]
+			if ok {
+				sd.metrics.cacheHit("stations")
+				req.resp <- v.value
+				if v.needsRefresh(sd.ttls.stations) {
+					v.refreshing = true
+					go backgroundFetch(req.lineID, sd.fetcher.fetchStation, refreshed, sd.done)
+				}
+				continue
+			}
+			if _, inFlight := pending[req.lineID]; inFlight {
+				sd.metrics.cacheCoalesced("stations")
+			} else {
+				sd.metrics.cacheMiss("stations")
+				go backgroundFetch(req.lineID, sd.fetcher.fetchStation, fetched, sd.done)
+			}
+			pending[req.lineID] = append(pending[req.lineID], req)
+		case res := <-fetched:
+			_stations := res.value
+			if res.err != nil {
+				sd.logger.Error("fetching stations", "line", res.key, "error", res.err)
+				_stations = []Station{}
+			}
+			if len(_stations) > 0 {
+				stations[res.key] = newCachedValue(_stations)
+				sd.metrics.cacheSize("stations", len(stations))
+			}
+			for _, req := range pending[res.key] {
+				req.resp <- _stations
+			}
+			delete(pending, res.key)
+		case res := <-refreshed:
+			entry := stations[res.key]
+			entry.refreshing = false
+			if res.err != nil || len(res.value) == 0 {
+				sd.logger.Error("refreshing stations, keeping cached data", "line", res.key, "error", res.err)
+				continue
+			}
+			stations[res.key] = newCachedValue(res.value)
+			sd.metrics.cacheSize("stations", len(stations))
 		}
-		stations[req.lineID] = _stations
-		req.resp <- _stations
 	}
 }
 
 func (sd *tflAPIImpl) monitorRouteFetch() {
-	routes := map[string][]Route{}
-	for req := range sd.routeRequests {
-		v, ok := routes[req.lineID]
-		if ok {
-			req.resp <- v
-			continue
-		}
-		_routes, err := sd.fetcher.fetchRoutes(req.lineID)
-		if err != nil {
-			log.Printf("ERROR fetching routes: %v", err)
-			req.resp <- []Route{}
-			continue
+	routes := map[string]*cachedValue[[]Route]{}
+	if sd.seed != nil {
+		routes = restoreEntries(sd.seed.Routes)
+		sd.metrics.cacheSize("routes", len(routes))
+	}
+	pending := map[string][]routeRequest{}
+	fetched := make(chan fetchResult[string, []Route])
+	refreshed := make(chan fetchResult[string, []Route])
+	for {
+		select {
+		case <-sd.done:
+			return
+		case p := <-sd.routePings:
+			p <- len(routes)
+		case sr := <-sd.routeSnapshots:
+			sr.snap.Routes = snapshotEntries(routes)
+			close(sr.done)
+		case req := <-sd.routeRequests:
+			v, ok := routes[req.lineID]
+			if ok {
+				sd.metrics.cacheHit("routes")
+				req.resp <- routeResponse{routes: v.value}
+				if v.needsRefresh(sd.ttls.routes) {
+					v.refreshing = true
+					go backgroundFetch(req.lineID, sd.fetcher.fetchRoutes, refreshed, sd.done)
+				}
+				continue
+			}
+			if _, inFlight := pending[req.lineID]; inFlight {
+				sd.metrics.cacheCoalesced("routes")
+			} else {
+				sd.metrics.cacheMiss("routes")
+				go backgroundFetch(req.lineID, sd.fetcher.fetchRoutes, fetched, sd.done)
+			}
+			pending[req.lineID] = append(pending[req.lineID], req)
+		case res := <-fetched:
+			resp := routeResponse{routes: res.value, err: res.err}
+			if res.err != nil {
+				sd.logger.Error("fetching routes", "line", res.key, "error", res.err)
+				resp.routes = []Route{}
+			} else if len(res.value) > 0 {
+				routes[res.key] = newCachedValue(res.value)
+				sd.metrics.cacheSize("routes", len(routes))
+			}
+			for _, req := range pending[res.key] {
+				req.resp <- resp
+			}
+			delete(pending, res.key)
+		case res := <-refreshed:
+			entry := routes[res.key]
+			entry.refreshing = false
+			if res.err != nil || len(res.value) == 0 {
+				sd.logger.Error("refreshing routes, keeping cached data", "line", res.key, "error", res.err)
+				continue
+			}
+			routes[res.key] = newCachedValue(res.value)
+			sd.metrics.cacheSize("routes", len(routes))
 		}
-		routes[req.lineID] = _routes
-		req.resp <- _routes
+	}
+}
+
+func (sd *tflAPIImpl) CacheStats() map[string]CacheStats {
+	return map[string]CacheStats{
+		"arrivals":      sd.arrivals.stats(),
+		"line_arrivals": sd.lineArrivals.stats(),
+		"vehicles":      sd.vehicles.stats(),
+		"status":        sd.statuses.stats(),
 	}
 }
 
 func (sd *tflAPIImpl) Lines(mode string, includeStatus bool) []Line {
-	lines := sd.lines(mode)
-	if len(lines) == 0 {
-		return lines
+	return sd.LinesContext(context.Background(), mode, includeStatus)
+}
+
+func (sd *tflAPIImpl) LinesContext(ctx context.Context, mode string, includeStatus bool) []Line {
+	lines, _ := sd.LookupLines(ctx, mode, includeStatus)
+	return lines
+}
+
+func (sd *tflAPIImpl) LookupLines(ctx context.Context, mode string, includeStatus bool) ([]Line, error) {
+	lines, err := sd.lines(ctx, mode)
+	if err != nil || len(lines) == 0 {
+		return lines, err
 	}
 	if !includeStatus {
-		return lines
+		return lines, nil
 	}
-	statuses, err := sd.fetcher.fetchStatus(mode)
+	// lines are still worth showing without their status
+	statuses, staleAsOf, err := sd.statuses.get(ctx, liveKey{id: mode})
 	if err != nil {
-		log.Printf("error getting status: %v", err)
-		return lines
+		sd.logger.Error("getting status", "mode", mode, "error", err)
+		return lines, nil
 	}
 	result := make([]Line, 0, len(lines))
 	for _, l := range lines {
 		l.Status = statuses[l.ID]
+		l.Status.StaleAsOf = staleAsOf
 		result = append(result, l)
 	}
-	return result
+	return result, nil
 }
 
-func (sd *tflAPIImpl) lines(mode string) []Line {
-	resp := make(chan []Line, 1)
+func (sd *tflAPIImpl) lines(ctx context.Context, mode string) ([]Line, error) {
+	resp := make(chan lineResponse, 1)
 	req := lineRequest{resp: resp, mode: mode}
 	select {
 	case sd.lineRequests <- req:
-		return <-resp
-	case <-time.After(time.Second * 5):
-		log.Printf("timeout waiting for remote request (line fetch)... processing one-off")
+		select {
+		case v := <-resp:
+			return v.lines, v.err
+		case <-ctx.Done():
+			return []Line{}, ctx.Err()
+		}
+	case <-time.After(sd.requestTimeout):
+		sd.metrics.requestTimeout("lines")
+		sd.logger.Warn("timed out waiting for lines cache, fetching directly", "mode", mode, "duration", sd.requestTimeout)
+	case <-ctx.Done():
+		return []Line{}, ctx.Err()
 	}
-	lines, err := sd.fetcher.fetchLines(mode)
+	lines, err := sd.fetcher.fetchLines(ctx, mode)
 	if err != nil {
-		log.Printf("ERROR fetching lines during one-off: %v", err)
-		return []Line{}
+		sd.logger.Error("fetching lines directly", "mode", mode, "error", err)
+		return []Line{}, err
 	}
-	return lines
+	return lines, nil
 }
 
 func (sd *tflAPIImpl) LineDetails(mode, lineID string) Line {
+	return sd.LineDetailsContext(context.Background(), mode, lineID)
+}
+
+func (sd *tflAPIImpl) LineDetailsContext(ctx context.Context, mode, lineID string) Line {
 	if lineID == "" {
-		log.Printf("WARNING: LineDetails called without lineID")
+		sd.logger.Warn("LineDetails called without line", "mode", mode)
 		return Line{}
 	}
 	if mode == "" {
-		log.Printf("WARNING: LineDetails called without mode")
+		sd.logger.Warn("LineDetails called without mode", "line", lineID)
 		return Line{
 			ID:   lineID,
 			Name: lineID,
 		}
 	}
-	resp := make(chan []Line, 1)
+	resp := make(chan lineResponse, 1)
 	req := lineRequest{resp: resp, mode: mode, lineID: lineID}
 	select {
 	case sd.lineRequests <- req:
-		v := <-resp
-		if len(v) != 1 {
-			return Line{}
+		select {
+		case v := <-resp:
+			if len(v.lines) != 1 {
+				return Line{}
+			}
+			return v.lines[0]
+		case <-ctx.Done():
 		}
-		return v[0]
-	case <-time.After(time.Second * 5):
-		log.Printf("timeout waiting for remote request (line details fetch)... aborting")
+	case <-time.After(sd.requestTimeout):
+		sd.metrics.requestTimeout("line_details")
+		sd.logger.Warn("timed out waiting for lines cache, aborting", "mode", mode, "line", lineID, "duration", sd.requestTimeout)
+	case <-ctx.Done():
 	}
 	return Line{}
 }
 
 func (sd *tflAPIImpl) Stations(lineID string) []Station {
+	return sd.StationsContext(context.Background(), lineID)
+}
+
+func (sd *tflAPIImpl) StationsContext(ctx context.Context, lineID string) []Station {
 	resp := make(chan []Station, 1)
 	req := stationRequest{lineID: lineID, resp: resp}
 	select {
 	case sd.stationRequests <- req:
-		return <-resp
-	case <-time.After(time.Second * 5):
-		log.Printf("timeout waiting for remote request (stations fetch)... processing one-off")
+		select {
+		case v := <-resp:
+			return v
+		case <-ctx.Done():
+			return []Station{}
+		}
+	case <-time.After(sd.requestTimeout):
+		sd.metrics.requestTimeout("stations")
+		sd.logger.Warn("timed out waiting for stations cache, fetching directly", "line", lineID, "duration", sd.requestTimeout)
+	case <-ctx.Done():
+		return []Station{}
 	}
-	stations, err := sd.fetcher.fetchStation(lineID)
+	stations, err := sd.fetcher.fetchStation(ctx, lineID)
 	if err != nil {
-		log.Printf("ERROR fetching stations during one-off: %v", err)
+		sd.logger.Error("fetching stations directly", "line", lineID, "error", err)
 		return []Station{}
 	}
 	return stations
 }
 
 func (sd *tflAPIImpl) Routes(lineID string) []Route {
-	resp := make(chan []Route, 1)
+	return sd.RoutesContext(context.Background(), lineID)
+}
+
+func (sd *tflAPIImpl) RoutesContext(ctx context.Context, lineID string) []Route {
+	routes, _ := sd.LookupRoutes(ctx, lineID)
+	return routes
+}
+
+func (sd *tflAPIImpl) LookupRoutes(ctx context.Context, lineID string) ([]Route, error) {
+	resp := make(chan routeResponse, 1)
 	req := routeRequest{lineID: lineID, resp: resp}
 	select {
 	case sd.routeRequests <- req:
-		return <-resp
-	case <-time.After(time.Second * 5):
-		log.Printf("timeout waiting for remote request (routes fetch)... processing one-off")
+		select {
+		case v := <-resp:
+			return v.routes, v.err
+		case <-ctx.Done():
+			return []Route{}, ctx.Err()
+		}
+	case <-time.After(sd.requestTimeout):
+		sd.metrics.requestTimeout("routes")
+		sd.logger.Warn("timed out waiting for routes cache, fetching directly", "line", lineID, "duration", sd.requestTimeout)
+	case <-ctx.Done():
+		return []Route{}, ctx.Err()
 	}
-	routes, err := sd.fetcher.fetchRoutes(lineID)
+	routes, err := sd.fetcher.fetchRoutes(ctx, lineID)
 	if err != nil {
-		log.Printf("ERROR fetching routes during one-off: %v", err)
-		return []Route{}
+		sd.logger.Error("fetching routes directly", "line", lineID, "error", err)
+		return []Route{}, err
 	}
-	return routes
+	return routes, nil
 }
 
 type remoteTFLHTTPFetcher struct {
-	c            http.Client
-	linesURL     func(string) string
-	stationsURL  func(string) string
-	routesURL    func(string) string
-	statusURL    func(string) string
-	timetableURL func(string, string, string) string
-	arrivalsURL  func(string, string) string
-	vehiclesURL  func(string) string
-}
-
-func newStaticFetcher() *remoteTFLHTTPFetcher {
-	c := http.Client{Timeout: time.Duration(5) * time.Second}
-	return &remoteTFLHTTPFetcher{
-		c: c,
-		linesURL: func(mode string) string {
-			return logURL(fmt.Sprintf(LineRoutesAPI, mode))
-		},
-		stationsURL: func(lineID string) string {
-			return logURL(fmt.Sprintf(LineStationsAPI, lineID))
-		},
-		routesURL: func(lineID string) string {
-			return logURL(fmt.Sprintf(LineStationSequenceAPI, lineID))
-		},
-		statusURL: func(mode string) string {
-			return logURL(fmt.Sprintf(LineStatusAPI, mode))
-		},
-		timetableURL: func(lineID, srcStation, destStation string) string {
-			return logURL(fmt.Sprintf(TimetablesAPI, lineID, srcStation, destStation))
-		},
-		arrivalsURL: func(lineID, stationID string) string {
-			return logURL(fmt.Sprintf(LineArrivalsAPI, lineID, stationID))
-		},
-		vehiclesURL: func(vid string) string {
-			return logURL(fmt.Sprintf(VehicleArrivalsAPI, vid))
-		},
-	}
-}
-
-func logURL(v string) string {
-	log.Println(v)
-	return v
+	c               *http.Client
+	logger          *slog.Logger
+	baseURL         string
+	appID           string
+	appKey          string
+	limiter         *rateLimiter
+	retry           retryPolicy
+	breakers        *circuitBreakers
+	recorder        *responseRecorder
+	replayer        *responseRecorder
+	metrics         *tflMetrics
+	health          *upstreamHealth
+	linesURL        func(string) string
+	stationsURL     func(string) string
+	routesURL       func(string) string
+	statusURL       func(string) string
+	timetableURL    func(string, string, string) string
+	arrivalsURL     func(string, string) string
+	lineArrivalsURL func(string) string
+	vehiclesURL     func(string) string
+}
+
+func newRemoteFetcher(cfg config, m *tflMetrics, done <-chan struct{}) *remoteTFLHTTPFetcher {
+	sf := &remoteTFLHTTPFetcher{
+		c:        cfg.httpClient,
+		logger:   cfg.logger,
+		baseURL:  cfg.baseURL,
+		appID:    cfg.appID,
+		appKey:   cfg.appKey,
+		retry:    newRetryPolicy(cfg.retry.maxAttempts, cfg.retry.baseDelay, cfg.retry.maxDelay),
+		breakers: newCircuitBreakers(cfg.breakerFailures, cfg.breakerOpenFor),
+		metrics:  m,
+		health:   &upstreamHealth{},
+	}
+	sf.limiter = newRateLimiter(cfg.rateLimit, cfg.rateBurst, done)
+	if cfg.replayDir != "" {
+		sf.replayer = newResponseRecorder(cfg.replayDir)
+	} else if cfg.recordDir != "" {
+		sf.recorder = newResponseRecorder(cfg.recordDir)
+	}
+	sf.linesURL = func(mode string) string {
+		return sf.apiURL(fmt.Sprintf(LineRoutesAPI, mode))
+	}
+	sf.stationsURL = func(lineID string) string {
+		return sf.apiURL(fmt.Sprintf(LineStationsAPI, lineID))
+	}
+	sf.routesURL = func(lineID string) string {
+		return sf.apiURL(fmt.Sprintf(LineStationSequenceAPI, lineID))
+	}
+	sf.statusURL = func(mode string) string {
+		return sf.apiURL(fmt.Sprintf(LineStatusAPI, mode))
+	}
+	sf.timetableURL = func(lineID, srcStation, destStation string) string {
+		return sf.apiURL(fmt.Sprintf(TimetablesAPI, lineID, srcStation, destStation))
+	}
+	sf.arrivalsURL = func(lineID, stationID string) string {
+		return sf.apiURL(fmt.Sprintf(LineArrivalsAPI, lineID, stationID))
+	}
+	sf.lineArrivalsURL = func(lineID string) string {
+		return sf.apiURL(fmt.Sprintf(LineAllArrivalsAPI, lineID))
+	}
+	sf.vehiclesURL = func(vid string) string {
+		return sf.apiURL(fmt.Sprintf(VehicleArrivalsAPI, vid))
+	}
+	return sf
+}
+
+// apiURL resolves path against the base URL and adds the credentials, if any
+func (sf *remoteTFLHTTPFetcher) apiURL(path string) string {
+	u, err := url.Parse(sf.baseURL + path)
+	if err != nil {
+		sf.logger.Error("unable to parse API URL", "path", path, "error", err)
+		return sf.baseURL + path
+	}
+	q := u.Query()
+	if sf.appID != "" {
+		q.Set("app_id", sf.appID)
+	}
+	if sf.appKey != "" {
+		q.Set("app_key", sf.appKey)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// get fetches url and returns the body of a successful response.
+// Failures are reported as *APIError so callers can branch on the sentinel errors.
+func (sf *remoteTFLHTTPFetcher) get(ctx context.Context, endpoint, url string) ([]byte, error) {
+	var body []byte
+	var err error
+	if sf.replayer != nil {
+		body, err = sf.getOnce(ctx, endpoint, url)
+	} else {
+		body, err = sf.getWithRetries(ctx, endpoint, url)
+	}
+	// a caller giving up says nothing about TfL
+	if ctx.Err() == nil {
+		sf.health.record(err)
+	}
+	return body, err
+}
+
+func (sf *remoteTFLHTTPFetcher) getOnce(ctx context.Context, endpoint, url string) ([]byte, error) {
+	resp, err := sf.do(ctx, endpoint, url)
+	if err != nil {
+		return nil, err
+	}
+	if err := newStatusError(endpoint, resp.statusCode, resp.retryAfter); err != nil {
+		return nil, err
+	}
+	return resp.body, nil
+}
+
+type fetchedResponse struct {
+	statusCode int
+	retryAfter string
+	body       []byte
+}
+
+// do performs the request, or serves it from the replay directory when replaying.
+// When recording, every response that made it back from TfL is saved.
+func (sf *remoteTFLHTTPFetcher) do(ctx context.Context, endpoint, url string) (fetchedResponse, error) {
+	if sf.replayer != nil {
+		return sf.replayer.load(endpoint, url)
+	}
+	if err := sf.limiter.wait(ctx, endpointPriority(endpoint)); err != nil {
+		return fetchedResponse{}, newUnavailableError(endpoint, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fetchedResponse{}, err
+	}
+	start := time.Now()
+	resp, err := sf.c.Do(req)
+	if err != nil {
+		sf.metrics.observeUpstream(endpoint, 0, err, time.Since(start))
+		sf.logger.Debug("TfL request failed", "endpoint", endpoint, "url", redactURL(url), "duration", time.Since(start), "error", err)
+		return fetchedResponse{}, newUnavailableError(endpoint, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	sf.metrics.observeUpstream(endpoint, resp.StatusCode, err, time.Since(start))
+	sf.logger.Debug("TfL request", "endpoint", endpoint, "url", redactURL(url), "status", resp.StatusCode, "duration", time.Since(start))
+	if err != nil {
+		return fetchedResponse{}, newUnavailableError(endpoint, err)
+	}
+	result := fetchedResponse{
+		statusCode: resp.StatusCode,
+		retryAfter: resp.Header.Get("Retry-After"),
+		body:       body,
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		pause := parseRetryAfter(result.retryAfter, time.Now())
+		if pause == 0 {
+			pause = defaultRateLimitPause
+		}
+		sf.logger.Warn("rate limited by TfL, pausing all requests", "endpoint", endpoint, "duration", pause)
+		sf.limiter.pauseFor(pause)
+	}
+	if sf.recorder != nil {
+		if err := sf.recorder.save(url, result); err != nil {
+			sf.logger.Error("recording response", "url", redactURL(url), "error", err)
+		}
+	}
+	return result, nil
+}
+
+func redactURL(v string) string {
+	u, err := url.Parse(v)
+	if err != nil {
+		return v
+	}
+	q := u.Query()
+	if q.Get("app_key") == "" {
+		return v
+	}
+	q.Set("app_key", "REDACTED")
+	u.RawQuery = q.Encode()
+	return u.String()
 }
 
 type tflLine struct {
@@ -332,20 +804,14 @@ type routeSection struct {
 	Name string
 }
 
-func (sf *remoteTFLHTTPFetcher) fetchLines(mode string) ([]Line, error) {
-	url := sf.linesURL(mode)
-	resp, err := sf.c.Get(url)
+func (sf *remoteTFLHTTPFetcher) fetchLines(ctx context.Context, mode string) ([]Line, error) {
+	body, err := sf.get(ctx, "lines", sf.linesURL(mode))
 	if err != nil {
-		return []Line{}, fmt.Errorf("problem fetching lines data from API: %v", err)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return []Line{}, fmt.Errorf("problem reading lines data from response: %v", err)
+		return []Line{}, fmt.Errorf("problem fetching lines data from API: %w", err)
 	}
 	tflLines := []tflLine{}
 	if err := json.Unmarshal(body, &tflLines); err != nil {
-		return []Line{}, fmt.Errorf("problem parsing lines response data from TFL: %v", err)
+		return []Line{}, fmt.Errorf("problem parsing lines response data from TFL: %w", newDecodeError("lines", err))
 	}
 	result := make([]Line, 0, len(tflLines))
 	for _, tflLine := range tflLines {
@@ -367,20 +833,14 @@ type tflStation struct {
 	Lat, Lon   float64
 }
 
-func (sf *remoteTFLHTTPFetcher) fetchStation(lineID string) ([]Station, error) {
-	url := sf.stationsURL(lineID)
-	resp, err := sf.c.Get(url)
-	if err != nil {
-		return []Station{}, fmt.Errorf("problem fetching station data for %s from API: %v", lineID, err)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+func (sf *remoteTFLHTTPFetcher) fetchStation(ctx context.Context, lineID string) ([]Station, error) {
+	body, err := sf.get(ctx, "stations", sf.stationsURL(lineID))
 	if err != nil {
-		return []Station{}, fmt.Errorf("problem reading station data for %s from response: %v", lineID, err)
+		return []Station{}, fmt.Errorf("problem fetching station data for %s from API: %w", lineID, err)
 	}
 	stations := []tflStation{}
 	if err := json.Unmarshal(body, &stations); err != nil {
-		return []Station{}, fmt.Errorf("problem parsing station data response data for %s from TFL: %v", lineID, err)
+		return []Station{}, fmt.Errorf("problem parsing station data response data for %s from TFL: %w", lineID, newDecodeError("stations", err))
 	}
 	result := make([]Station, 0, len(stations))
 	for _, s := range stations {
@@ -406,11 +866,11 @@ type tflLineRoute struct {
 	NaptanIds []string
 }
 
-func (sf *remoteTFLHTTPFetcher) fetchRoutes(lineID string) ([]Route, error) {
+func (sf *remoteTFLHTTPFetcher) fetchRoutes(ctx context.Context, lineID string) ([]Route, error) {
 	// Get stations first and create a hashmap
-	allStations, err := sf.fetchStation(lineID)
+	allStations, err := sf.fetchStation(ctx, lineID)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching stations while fetching routes: %v", err)
+		return nil, fmt.Errorf("error fetching stations while fetching routes: %w", err)
 	}
 	stationsMap := make(map[string]Station)
 	for _, s := range allStations {
@@ -418,19 +878,13 @@ func (sf *remoteTFLHTTPFetcher) fetchRoutes(lineID string) ([]Route, error) {
 	}
 
 	// Now move on to routes
-	url := sf.routesURL(lineID)
-	resp, err := sf.c.Get(url)
+	body, err := sf.get(ctx, "routes", sf.routesURL(lineID))
 	if err != nil {
-		return []Route{}, fmt.Errorf("problem fetching routes data for %s from API: %v", lineID, err)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return []Route{}, fmt.Errorf("problem reading routes data for %s from response: %v", lineID, err)
+		return []Route{}, fmt.Errorf("problem fetching routes data for %s from API: %w", lineID, err)
 	}
 	routeSequence := tflRouteSequence{}
 	if err := json.Unmarshal(body, &routeSequence); err != nil {
-		return []Route{}, fmt.Errorf("problem parsing routes data response data for %s from TFL: %v", lineID, err)
+		return []Route{}, fmt.Errorf("problem parsing routes data response data for %s from TFL: %w", lineID, newDecodeError("routes", err))
 	}
 
 	// Prepare final output
@@ -440,7 +894,7 @@ func (sf *remoteTFLHTTPFetcher) fetchRoutes(lineID string) ([]Route, error) {
 		for _, stationID := range olr.NaptanIds {
 			station, ok := stationsMap[stationID]
 			if !ok {
-				log.Printf("station with ID %s found in route %s but not in collection", stationID, olr.Name)
+				sf.logger.Warn("station found in route but not in the line's stop points", "line", lineID, "station", stationID, "route", olr.Name)
 				continue
 			}
 			stations = append(stations, station)
@@ -482,20 +936,14 @@ func (s tflStatus) statusDescriptions() []string {
 	return result
 }
 
-func (sf *remoteTFLHTTPFetcher) fetchStatus(mode string) (map[string]Status, error) {
-	url := sf.statusURL(mode)
-	resp, err := sf.c.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("problem fetching status data from API: %v", err)
-	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+func (sf *remoteTFLHTTPFetcher) fetchStatus(ctx context.Context, mode string) (map[string]Status, error) {
+	body, err := sf.get(ctx, "status", sf.statusURL(mode))
 	if err != nil {
-		return nil, fmt.Errorf("problem reading status data from response: %v", err)
+		return nil, fmt.Errorf("problem fetching status data from API: %w", err)
 	}
 	statuses := []tflStatus{}
 	if err := json.Unmarshal(body, &statuses); err != nil {
-		return nil, fmt.Errorf("problem parsing status data from TFL: %v", err)
+		return nil, fmt.Errorf("problem parsing status data from TFL: %w", newDecodeError("status", err))
 	}
 	result := make(map[string]Status)
 	for _, s := range statuses {
@@ -0,0 +1,175 @@
+package tfltest
+
+import (
+	"fmt"
+
+	"github.com/arunsworld/tfl"
+)
+
+// LinesPath is the path of the tfl.LineRoutesAPI endpoint.
+func LinesPath(mode string) string {
+	return endpointPath(fmt.Sprintf(tfl.LineRoutesAPI, mode))
+}
+
+// StopPointsPath is the path of the tfl.LineStationsAPI endpoint.
+func StopPointsPath(lineID string) string {
+	return endpointPath(fmt.Sprintf(tfl.LineStationsAPI, lineID))
+}
+
+// StatusPath is the path of the tfl.LineStatusAPI endpoint.
+func StatusPath(mode string) string {
+	return endpointPath(fmt.Sprintf(tfl.LineStatusAPI, mode))
+}
+
+// ArrivalsPath is the path of the tfl.LineArrivalsAPI endpoint.
+func ArrivalsPath(lineID, stationID string) string {
+	return endpointPath(fmt.Sprintf(tfl.LineArrivalsAPI, lineID, stationID))
+}
+
+// LineArrivalsPath is the path of the tfl.LineAllArrivalsAPI endpoint.
+func LineArrivalsPath(lineID string) string {
+	return endpointPath(fmt.Sprintf(tfl.LineAllArrivalsAPI, lineID))
+}
+
+// RouteSequencePath is the path of the tfl.LineStationSequenceAPI endpoint.
+func RouteSequencePath(lineID string) string {
+	return endpointPath(fmt.Sprintf(tfl.LineStationSequenceAPI, lineID))
+}
+
+// VehicleArrivalsPath is the path of the tfl.VehicleArrivalsAPI endpoint.
+func VehicleArrivalsPath(vehicleID string) string {
+	return endpointPath(fmt.Sprintf(tfl.VehicleArrivalsAPI, vehicleID))
+}
+
+// TimetablePath is the path of the tfl.TimetablesAPI endpoint.
+func TimetablePath(lineID, fromStationID, toStationID string) string {
+	return endpointPath(fmt.Sprintf(tfl.TimetablesAPI, lineID, fromStationID, toStationID))
+}
+
+// The types below mirror the subset of the TfL JSON that package tfl reads.
+
+type Line struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type StopPoint struct {
+	ID         string  `json:"id"`
+	CommonName string  `json:"commonName"`
+	Lat        float64 `json:"lat"`
+	Lon        float64 `json:"lon"`
+}
+
+type OrderedLineRoute struct {
+	Name      string   `json:"name"`
+	NaptanIDs []string `json:"naptanIds"`
+}
+
+type LineStatus struct {
+	ID           string             `json:"id"`
+	LineStatuses []LineStatusDetail `json:"lineStatuses"`
+}
+
+type LineStatusDetail struct {
+	StatusSeverityDescription string `json:"statusSeverityDescription"`
+	Reason                    string `json:"reason,omitempty"`
+}
+
+// Arrival is a TfL prediction, served by both the line and the vehicle arrivals endpoints.
+type Arrival struct {
+	VehicleID       string `json:"vehicleId"`
+	NaptanID        string `json:"naptanId"`
+	StationName     string `json:"stationName"`
+	LineID          string `json:"lineId"`
+	LineName        string `json:"lineName"`
+	PlatformName    string `json:"platformName"`
+	DestinationName string `json:"destinationName,omitempty"`
+	Towards         string `json:"towards"`
+	CurrentLocation string `json:"currentLocation"`
+	TimeToStation   int    `json:"timeToStation"`
+	ExpectedArrival string `json:"expectedArrival"`
+}
+
+type Timetable struct {
+	Stops     []TimetableStop    `json:"stops"`
+	Timetable TimetableRouteList `json:"timetable"`
+}
+
+type TimetableStop struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type TimetableRouteList struct {
+	Routes []TimetableRoute `json:"routes"`
+}
+
+type TimetableRoute struct {
+	StationIntervals []StationInterval `json:"stationIntervals"`
+	Schedules        []Schedule        `json:"schedules"`
+}
+
+type StationInterval struct {
+	ID        string     `json:"id"`
+	Intervals []Interval `json:"intervals"`
+}
+
+type Interval struct {
+	StopID        string  `json:"stopId"`
+	TimeToArrival float64 `json:"timeToArrival"`
+}
+
+type Schedule struct {
+	Name          string         `json:"name"`
+	KnownJourneys []KnownJourney `json:"knownJourneys"`
+}
+
+type KnownJourney struct {
+	Hour       string `json:"hour"`
+	Minute     string `json:"minute"`
+	IntervalID int    `json:"intervalId"`
+}
+
+func (s *Server) SetLines(mode string, lines ...Line) {
+	s.SetJSON(LinesPath(mode), nonNil(lines))
+}
+
+func (s *Server) SetStopPoints(lineID string, stops ...StopPoint) {
+	s.SetJSON(StopPointsPath(lineID), nonNil(stops))
+}
+
+func (s *Server) SetStatus(mode string, statuses ...LineStatus) {
+	s.SetJSON(StatusPath(mode), nonNil(statuses))
+}
+
+func (s *Server) SetRouteSequence(lineID string, routes ...OrderedLineRoute) {
+	s.SetJSON(RouteSequencePath(lineID), struct {
+		OrderedLineRoutes []OrderedLineRoute `json:"orderedLineRoutes"`
+	}{
+		OrderedLineRoutes: nonNil(routes),
+	})
+}
+
+func (s *Server) SetArrivals(lineID, stationID string, arrivals ...Arrival) {
+	s.SetJSON(ArrivalsPath(lineID, stationID), nonNil(arrivals))
+}
+
+func (s *Server) SetLineArrivals(lineID string, arrivals ...Arrival) {
+	s.SetJSON(LineArrivalsPath(lineID), nonNil(arrivals))
+}
+
+func (s *Server) SetVehicleArrivals(vehicleID string, arrivals ...Arrival) {
+	s.SetJSON(VehicleArrivalsPath(vehicleID), nonNil(arrivals))
+}
+
+func (s *Server) SetTimetable(lineID, fromStationID, toStationID string, tt Timetable) {
+	s.SetJSON(TimetablePath(lineID, fromStationID, toStationID), tt)
+}
+
+// nonNil makes sure empty fixtures encode as [] like TfL does, not null
+func nonNil[K any](v []K) []K {
+	if v == nil {
+		return []K{}
+	}
+	return v
+}
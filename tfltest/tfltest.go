@@ -0,0 +1,251 @@
+// Package tfltest provides an in-process fake of the TfL Unified API for tests.
+//
+// A Server serves the endpoints in tfl-api.go from fixtures scripted by the test,
+// and can inject errors, latency and rate limiting (429s):
+//
+//	srv := tfltest.NewServer()
+//	defer srv.Close()
+//	srv.SetLines("tube", tfltest.Line{ID: "victoria", Name: "Victoria"})
+//	srv.RateLimitNext(tfltest.ArrivalsPath("victoria", "940GZZLUOXC"), 1, time.Second)
+//	api := tfl.New(tfl.WithBaseURL(srv.URL))
+package tfltest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arunsworld/tfl"
+)
+
+// AnyPath applies a fault or latency to every endpoint
+const AnyPath = "*"
+
+// Fixture is a scripted response for one path.
+type Fixture struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type fault struct {
+	status     int
+	retryAfter time.Duration
+}
+
+// Server is a fake TfL API. The embedded httptest.Server provides URL and Close.
+type Server struct {
+	*httptest.Server
+	mu       sync.Mutex
+	fixtures map[string]Fixture
+	faults   map[string][]fault
+	latency  map[string]time.Duration
+	requests []*url.URL
+}
+
+// NewServer starts a fake with no fixtures; every endpoint answers 404 until scripted.
+func NewServer() *Server {
+	s := &Server{
+		fixtures: make(map[string]Fixture),
+		faults:   make(map[string][]fault),
+		latency:  make(map[string]time.Duration),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Set scripts the response for path. Path must match one of the TfL endpoints in tfl-api.go,
+// eg. tfltest.ArrivalsPath("victoria", "940GZZLUOXC").
+func (s *Server) Set(path string, f Fixture) {
+	mustBeKnownEndpoint(path)
+	if f.Status == 0 {
+		f.Status = http.StatusOK
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures[path] = f
+}
+
+// SetJSON scripts a 200 response with v encoded as JSON.
+func (s *Server) SetJSON(path string, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("tfltest: unable to encode fixture for %s: %v", path, err))
+	}
+	s.Set(path, Fixture{
+		Status: http.StatusOK,
+		Header: http.Header{"Content-Type": []string{"application/json"}},
+		Body:   body,
+	})
+}
+
+// SetError scripts a TfL-style error response with the given status.
+func (s *Server) SetError(path string, status int) {
+	s.Set(path, errorFixture(path, status, 0))
+}
+
+// FailNext makes the next n requests to path (or AnyPath) answer with status,
+// after which the scripted fixture is served again.
+func (s *Server) FailNext(path string, n int, status int) {
+	s.addFaults(path, n, fault{status: status})
+}
+
+// RateLimitNext makes the next n requests to path (or AnyPath) answer 429 with a Retry-After header.
+func (s *Server) RateLimitNext(path string, n int, retryAfter time.Duration) {
+	s.addFaults(path, n, fault{status: http.StatusTooManyRequests, retryAfter: retryAfter})
+}
+
+func (s *Server) addFaults(path string, n int, f fault) {
+	if path != AnyPath {
+		mustBeKnownEndpoint(path)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.faults[path] = append(s.faults[path], f)
+	}
+}
+
+// SetLatency delays every response for path (or AnyPath) by d.
+func (s *Server) SetLatency(path string, d time.Duration) {
+	if path != AnyPath {
+		mustBeKnownEndpoint(path)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency[path] = d
+}
+
+// Requests returns the URLs requested so far, including query parameters such as app_key.
+func (s *Server) Requests() []*url.URL {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]*url.URL, len(s.requests))
+	copy(result, s.requests)
+	return result
+}
+
+// RequestCount returns how many times path has been requested.
+func (s *Server) RequestCount(path string) int {
+	count := 0
+	for _, u := range s.Requests() {
+		if u.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	u := *r.URL
+	s.mu.Lock()
+	s.requests = append(s.requests, &u)
+	delay := s.latency[AnyPath] + s.latency[path]
+	f, hasFault := s.nextFault(path)
+	fixture, hasFixture := s.fixtures[path]
+	s.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	switch {
+	case hasFault:
+		fixture = errorFixture(path, f.status, f.retryAfter)
+	case !hasFixture:
+		fixture = errorFixture(path, http.StatusNotFound, 0)
+	}
+	for k, v := range fixture.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(fixture.Status)
+	w.Write(fixture.Body)
+}
+
+// nextFault pops the next fault for path; the caller holds the lock
+func (s *Server) nextFault(path string) (fault, bool) {
+	for _, key := range []string{path, AnyPath} {
+		if fs := s.faults[key]; len(fs) > 0 {
+			s.faults[key] = fs[1:]
+			return fs[0], true
+		}
+	}
+	return fault{}, false
+}
+
+func errorFixture(path string, status int, retryAfter time.Duration) Fixture {
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	if retryAfter > 0 {
+		header.Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"$type":          "Tfl.Api.Presentation.Entities.ApiError, Tfl.Api.Presentation.Entities",
+		"timestampUtc":   time.Now().UTC().Format(time.RFC3339),
+		"exceptionType":  exceptionType(status),
+		"httpStatusCode": status,
+		"httpStatus":     http.StatusText(status),
+		"relativeUri":    path,
+		"message":        http.StatusText(status),
+	})
+	return Fixture{Status: status, Header: header, Body: body}
+}
+
+// exceptionType names the .NET exception TfL reports for status
+func exceptionType(status int) string {
+	switch {
+	case status == http.StatusNotFound:
+		return "EntityNotFoundException"
+	case status == http.StatusTooManyRequests:
+		return "TooManyRequestsException"
+	case status >= http.StatusInternalServerError:
+		return "ApiException"
+	default:
+		return "ApiArgumentException"
+	}
+}
+
+var knownEndpoints = func() []*regexp.Regexp {
+	templates := []string{
+		tfl.LineRoutesAPI,
+		tfl.LineStationsAPI,
+		tfl.LineStatusAPI,
+		tfl.LineArrivalsAPI,
+		tfl.LineAllArrivalsAPI,
+		tfl.LineStationSequenceAPI,
+		tfl.VehicleArrivalsAPI,
+		tfl.TimetablesAPI,
+	}
+	result := make([]*regexp.Regexp, 0, len(templates))
+	for _, t := range templates {
+		p := regexp.QuoteMeta(endpointPath(t))
+		p = strings.ReplaceAll(p, "%s", "[^/]+")
+		result = append(result, regexp.MustCompile("^"+p+"$"))
+	}
+	return result
+}()
+
+func mustBeKnownEndpoint(path string) {
+	for _, re := range knownEndpoints {
+		if re.MatchString(path) {
+			return
+		}
+	}
+	panic(fmt.Sprintf("tfltest: %s is not a TfL endpoint used by package tfl", path))
+}
+
+// endpointPath drops the query string from an API template
+func endpointPath(v string) string {
+	return strings.SplitN(v, "?", 2)[0]
+}
@@ -3,7 +3,7 @@ package webserver
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 )
@@ -27,20 +27,20 @@ func (w *httpWebServer) Serve(ctx context.Context, port int) error {
 	errCh := make(chan error, 1)
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Printf("server start error: %v", err)
+			slog.Error("server start error", "error", err)
 			errCh <- err
 		}
 	}()
-	log.Printf("Serving on URL: http://localhost:%d/", port)
+	slog.Info("serving", "url", fmt.Sprintf("http://localhost:%d/", port))
 	select {
 	case err := <-errCh:
 		return err
 	case <-ctx.Done():
-		log.Println("initiating graceful shutdown of server...")
+		slog.Info("initiating graceful shutdown of server")
 		ctxShutDown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		if err := srv.Shutdown(ctxShutDown); err != nil {
-			log.Printf("error during graceful shutdown: %v", err)
+			slog.Error("graceful shutdown", "error", err)
 		}
 		return nil
 	}